@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// check compares the outputs with the files under dir, and fails listing the stale, missing and extra files.
+// dir stands for the output directory of protoc, the manifest is read from it as the other outputs: the extra files
+// are the ones listed by the manifest, if any, which are not generated anymore.
+func (g *Generator) check(dir string, manifestName string) error {
+	names, contents, err := resolveOutputs(g.Response, dir)
+	if err != nil {
+		return err
+	}
+	var drifts []string
+	generated := make(map[string]bool, len(names))
+	for _, name := range names {
+		generated[name] = true
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name))) // #nosec
+		switch {
+		case os.IsNotExist(err):
+			drifts = append(drifts, "missing  "+name)
+		case err != nil:
+			return err
+		case string(data) != contents[name]:
+			drifts = append(drifts, "stale    "+name)
+		}
+	}
+
+	if manifestName != "" {
+		previous, err := loadManifest(filepath.Join(dir, filepath.FromSlash(manifestName)))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if previous != nil {
+			for _, f := range previous.Files {
+				if f.InsertionPoint != "" || generated[f.Name] {
+					continue
+				}
+				if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(f.Name))); err == nil {
+					drifts = append(drifts, "extra    "+f.Name)
+				}
+			}
+		}
+	}
+
+	if len(drifts) > 0 {
+		return fmt.Errorf("generated files out of date under %s:\n  %s", dir, strings.Join(drifts, "\n  "))
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// config is the file given by the config parameter, listing the jobs rendered in a single run, e.g.:
+//
+//	jobs:
+//	  - name: go
+//	    template_dir: templates/go
+//	    scope: message
+//	    include_files: [api/*.proto]
+//	    output_prefix: go
+//	    vars:
+//	      company: Acme
+//	  - template_dir: templates/docs
+//	    scope: file
+//
+// The keys of a job are the plugin parameters, except the global ones, plus name and vars.
+// A job inherits the parameters given to the plugin.
+type config struct {
+	Jobs []map[string]interface{} `yaml:"jobs"`
+}
+
+// loadConfig reads a configuration file and returns the options of its jobs.
+func loadConfig(path string, opts *options) ([]*options, error) {
+	data, err := ioutil.ReadFile(path) // #nosec
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	var c config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	if len(c.Jobs) == 0 {
+		return nil, fmt.Errorf("config %s: no jobs", path)
+	}
+
+	jobs := make([]*options, 0, len(c.Jobs))
+	for i, spec := range c.Jobs {
+		name := fmt.Sprintf("#%d", i+1)
+		if specName, ok := spec["name"]; ok {
+			name = fmt.Sprint(specName)
+		}
+		job, err := parseJob(spec, opts)
+		if err != nil {
+			return nil, fmt.Errorf("config %s: job %s: %w", path, name, err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// parseJob returns the options of a job of the configuration file.
+func parseJob(spec map[string]interface{}, opts *options) (*options, error) {
+	job := opts.job()
+	keys := make([]string, 0, len(spec))
+	for key := range spec {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// the vars of the job override the ones of its vars files
+	var vars map[string]interface{}
+	for _, key := range keys {
+		value := spec[key]
+		switch key {
+		case "name":
+			continue
+		case "vars":
+			var ok bool
+			if vars, ok = value.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("vars: expected a mapping, got %T", value)
+			}
+			continue
+		}
+
+		name := key
+		if prefix := keyedPrefix(key); prefix != "" {
+			name = prefix
+		}
+		p, ok := lookupParameter(name)
+		if !ok || (p.keyed && name == key) {
+			return nil, fmt.Errorf("unknown key %q, valid keys are: name, vars, %s", key, jobParameterNames())
+		}
+		if p.global {
+			return nil, fmt.Errorf("%s: only supported as a plugin parameter", key)
+		}
+		if p.name == "var." {
+			return nil, fmt.Errorf("%s: use vars in a job", key)
+		}
+		values := []interface{}{value}
+		if list, ok := value.([]interface{}); ok {
+			if !p.repeated {
+				return nil, fmt.Errorf("%s: expected a single value", key)
+			}
+			values = list
+		}
+		for _, value := range values {
+			value := fmt.Sprint(value)
+			if name != key {
+				value = strings.TrimPrefix(key, name) + "=" + value
+			}
+			if err := p.set(job, value); err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+		}
+	}
+
+	for name, value := range vars {
+		job.vars[name] = value
+	}
+	if len(job.templateDirs) == 0 {
+		job.templateDirs = opts.templateDirs
+	}
+	if len(job.files.Include) == 0 {
+		job.files.Include = opts.files.Include
+	}
+	if len(job.files.Exclude) == 0 {
+		job.files.Exclude = opts.files.Exclude
+	}
+	if len(job.templates.Include) == 0 {
+		job.templates.Include = opts.templates.Include
+	}
+	if len(job.templates.Exclude) == 0 {
+		job.templates.Exclude = opts.templates.Exclude
+	}
+	return job, nil
+}
+
+func jobParameterNames() string {
+	names := make([]string, 0, len(parameters))
+	for _, p := range parameters {
+		if !p.global && p.name != "var." {
+			names = append(names, p.name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// loadVarsFile reads a JSON or YAML file of variables into vars.
+func loadVarsFile(path string, vars map[string]interface{}) error {
+	data, err := ioutil.ReadFile(path) // #nosec
+	if err != nil {
+		return err
+	}
+	var fileVars map[string]interface{}
+	// JSON being a subset of YAML, both are read by the YAML decoder
+	if err := yaml.Unmarshal(data, &fileVars); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for name, value := range fileVars {
+		vars[name] = value
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	plugingo "google.golang.org/protobuf/types/pluginpb"
+)
+
+// dumpRequest returns the outputs holding the request, in binary and, with dump_request_json, as JSON.
+// They have to be built before the generation, which sorts the files of the request.
+func dumpRequest(request *plugingo.CodeGeneratorRequest, opts *options) ([]*plugingo.CodeGeneratorResponse_File, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("dump_request: %w", err)
+	}
+	dumps := []*plugingo.CodeGeneratorResponse_File{{
+		Name:    proto.String(opts.dumpRequest),
+		Content: proto.String(string(data)),
+	}}
+	if opts.dumpRequestJSON {
+		data, err := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(request)
+		if err != nil {
+			return nil, fmt.Errorf("dump_request_json: %w", err)
+		}
+		dumps = append(dumps, &plugingo.CodeGeneratorResponse_File{
+			Name:    proto.String(opts.dumpRequest + ".json"),
+			Content: proto.String(string(data) + "\n"),
+		})
+	}
+	return dumps, nil
+}
+
+// replayCommand runs the templates on a request written by dump_request, in binary or as JSON:
+//
+//	protoc-gen-gotemplate replay -templates ./templates gen/request.bin
+//
+// The outputs are printed, unless -out is given, in which case they are written to the directory.
+func replayCommand(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	var templateDirs stringsFlag
+	flags.Var(&templateDirs, "templates", "template directory, replacing the template_dir parameters, can be repeated")
+	param := flags.String("param", "", "parameters of the plugin, as given to protoc, replacing the ones of the request")
+	out := flags.String("out", "", "directory the outputs are written to, instead of being printed")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: protoc-gen-gotemplate replay [flags] <request>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+
+	request, err := loadRequest(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	// the request is not dumped again
+	if err := overrideParameters(request, *param, templateDirs, "dump_request", "dump_request_json"); err != nil {
+		return err
+	}
+
+	response := generate(request)
+	if *out != "" {
+		return writeResponse(response, *out)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("generation failed: %s", response.GetError())
+	}
+	for _, file := range response.File {
+		if file.InsertionPoint != nil {
+			fmt.Fprintf(os.Stdout, "==> %s @ %s <==\n", file.GetName(), file.GetInsertionPoint())
+		} else {
+			fmt.Fprintf(os.Stdout, "==> %s <==\n", file.GetName())
+		}
+		fmt.Fprintln(os.Stdout, file.GetContent())
+	}
+	return nil
+}
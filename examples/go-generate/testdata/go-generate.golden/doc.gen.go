@@ -0,0 +1,12 @@
+// Code generated by protoc-gen-gotemplate
+
+package gen
+
+// Methods
+// -------
+// * Sum
+//
+// Message types
+// -------------
+// * SumRequest
+// * SumReply
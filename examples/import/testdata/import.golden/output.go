@@ -0,0 +1,40 @@
+// Code generated by protoc-gen-gotemplate
+package company
+
+import (
+	"github.com/chrismoran-blockfi/protoc-gen-gotemplate/examples/import/output/models/article"
+    "github.com/chrismoran-blockfi/protoc-gen-gotemplate/examples/import/output/models/common"
+)
+
+type Repository interface {
+     GetArticle(getarticle *common.GetArticle ) (*company.Article, []*company.Storage,  error)
+}
+
+
+
+
+
+
+
+// ------------------------- Public SDK -----------------------------
+
+
+
+
+
+
+
+// GetArticle :  
+func (sdk *Sdk) GetArticle(ctx context.Context, 
+  getarticle *article.GetArticle, token, requestID string)(article *article.Article, storages []*article.GetArticleResponse_Storage, err error) {
+
+  out := &pb.GetArticleResponse{}
+	_ = out
+
+
+  return out.Article, out.Storages, nil
+ 
+}
+
+ 
+ 
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/chrismoran-blockfi/protoc-gen-gotemplate/helpers"
+	"google.golang.org/protobuf/proto"
+	descriptor "google.golang.org/protobuf/types/descriptorpb"
+	plugingo "google.golang.org/protobuf/types/pluginpb"
+)
+
+// Generate renders the templates for the request and fills the response with the generated files.
+// Rendering goes on after a template failure, so that every failure is reported at once.
+func (g *Generator) Generate(opts *options) error {
+	if len(g.Request.FileToGenerate) == 0 {
+		return errors.New("no files to generate")
+	}
+
+	tmplMap := make(map[string]*plugingo.CodeGeneratorResponse_File)
+	ipMap := make(map[string]bool)
+	g.sources = make(map[*plugingo.CodeGeneratorResponse_File][]source)
+	concatOrAppend := func(output *helpers.Output) {
+		file := output.CodeGeneratorResponse_File
+		key := fmt.Sprintf("%s:%s", file.GetName(), file.GetInsertionPoint())
+		baseFile := fmt.Sprintf("%s:", file.GetName())
+
+		if val, ok := tmplMap[key]; ok {
+			g.sources[val] = append(g.sources[val], source{Output: output, line: strings.Count(val.GetContent(), "\n") + 1})
+			*val.Content += file.GetContent()
+		} else {
+			if key == baseFile {
+				tmplMap[key] = file
+				ipMap[key] = true
+			}
+			if exists, isOk := ipMap[baseFile]; !isOk || !exists {
+				if opts.debug {
+					_, _ = fmt.Fprintf(os.Stderr, "%s does not exist, skipping %s\n", baseFile, key)
+				}
+			} else {
+				tmplMap[key] = file
+				ipMap[baseFile] = true
+				g.sources[file] = []source{{Output: output, line: 1}}
+				g.Response.File = append(g.Response.File, file)
+			}
+		}
+	}
+
+	var registry *helpers.Registry
+	if opts.singlePackageMode {
+		registry = helpers.NewRegistry()
+		for file, importPath := range opts.pkgMap {
+			registry.AddPkgMap(file, importPath)
+		}
+		helpers.SetRegistry(registry)
+		if err := registry.Load(g.Request); err != nil {
+			return fmt.Errorf("registry: failed to load the request: %w", err)
+		}
+	}
+
+	jobs := []*options{opts}
+	if opts.config != "" {
+		var err error
+		if jobs, err = loadConfig(opts.config, opts); err != nil {
+			return err
+		}
+	}
+
+	// In reproducible mode, the date of the build is pinned and the nondeterministic helpers are replaced
+	var buildDate time.Time
+	var funcs template.FuncMap
+	if opts.reproducible {
+		var err error
+		if buildDate, err = helpers.SourceDate(); err != nil {
+			return err
+		}
+		funcs = helpers.ReproducibleFuncMap(buildDate)
+	}
+
+	var errs helpers.Errors
+	var encoders []*helpers.GenericTemplateBasedEncoder
+	// prefixes holds the output prefix of the job of every encoder
+	var prefixes []string
+	// The templates are read and parsed once, then shared by every encoder
+	sets := make(map[string]*helpers.TemplateSet)
+	sort.Sort(helpers.RequestFileSorter{Request: g.Request})
+	for _, job := range jobs {
+		var header *helpers.Header
+		if job.headerName != "" {
+			var err error
+			if header, err = helpers.ParseHeader(job.headerName, job.header, funcs); err != nil {
+				return fmt.Errorf("header: %w", err)
+			}
+			header.Insertions = job.headerInsertions
+		}
+		for _, templateDir := range job.templateDirs {
+			key := fmt.Sprintf("%q %q %q", templateDir, job.templates.Include, job.templates.Exclude)
+			templates, ok := sets[key]
+			if !ok {
+				var err error
+				if templates, err = helpers.LoadTemplateSet(templateDir, job.templates, funcs, opts.debug); err != nil {
+					return fmt.Errorf("cannot get templates from %s: %w", templateDir, err)
+				}
+				sets[key] = templates
+			}
+			jobEncoders := g.encoders(job, registry, templates, &errs)
+			for _, encoder := range jobEncoders {
+				if opts.reproducible {
+					encoder.SetReproducible(buildDate)
+				}
+				encoder.SetHeader(header)
+			}
+			encoders = append(encoders, jobEncoders...)
+			for range jobEncoders {
+				prefixes = append(prefixes, job.outputPrefix)
+			}
+		}
+	}
+
+	// Every (encoder, template) pair is rendered by a bounded pool of workers, the outputs are
+	// then merged in the order of the encoders so that concatenations are deterministic
+	outputs, err := helpers.RenderOutputs(encoders, opts.jobs)
+	var renderErrs helpers.Errors
+	if errors.As(err, &renderErrs) {
+		errs = append(errs, renderErrs...)
+	} else if err != nil {
+		errs = append(errs, err)
+	}
+	for i, encoderOutputs := range outputs {
+		for _, output := range encoderOutputs {
+			if prefixes[i] != "" {
+				output.Name = proto.String(path.Join(prefixes[i], output.GetName()))
+			}
+			concatOrAppend(output)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	g.prependHeaders()
+	if opts.validate {
+		if err := g.validate(); err != nil {
+			return err
+		}
+	}
+	if opts.manifest != "" {
+		return g.addManifest(opts.manifest)
+	}
+	return nil
+}
+
+// prependHeaders prepends a single header to every generated file, the one rendered for its first source,
+// once the outputs are concatenated. The lines of the sources are shifted accordingly.
+func (g *Generator) prependHeaders() {
+	for _, file := range g.Response.File {
+		sources := g.sources[file]
+		if len(sources) == 0 || sources[0].Header == "" {
+			continue
+		}
+		content := helpers.PrependHeader(file.GetContent(), sources[0].Header, file.GetInsertionPoint() != "")
+		shift := strings.Count(content, "\n") - strings.Count(file.GetContent(), "\n")
+		for i := range sources {
+			sources[i].line += shift
+		}
+		file.Content = proto.String(content)
+	}
+}
+
+// source is an output concatenated into a generated file, from the given line.
+type source struct {
+	*helpers.Output
+	line int
+}
+
+// encoders returns the encoders rendering a template set for the request, in the order of its files.
+func (g *Generator) encoders(opts *options, registry *helpers.Registry, templates *helpers.TemplateSet, errs *helpers.Errors) []*helpers.GenericTemplateBasedEncoder {
+	scope := opts.defaultScope()
+	// Templates declaring another scope in their front matter are rendered in an additional pass
+	scopes := []helpers.Scope{scope}
+	for _, s := range templates.Scopes() {
+		if s != scope {
+			scopes = append(scopes, s)
+		}
+	}
+
+	// Only the files given to protoc are rendered, unless include_imports is set
+	toGenerate := make(map[string]bool, len(g.Request.GetFileToGenerate()))
+	for _, name := range g.Request.GetFileToGenerate() {
+		toGenerate[name] = true
+	}
+	generates := func(file *descriptor.FileDescriptorProto) bool {
+		return (opts.includeImports || toGenerate[file.GetName()]) && opts.generates(file.GetName())
+	}
+
+	postProcessing := opts.postProcessors()
+	var encoders []*helpers.GenericTemplateBasedEncoder
+	// dependency is set while rendering an imported file
+	dependency := false
+	render := func(encoder *helpers.GenericTemplateBasedEncoder) {
+		encoder.SetDependency(dependency)
+		encoder.SetTemplateSet(templates)
+		encoder.SetDefaultScope(scope)
+		encoder.SetDropEmpty(opts.dropEmpty)
+		encoder.SetVars(opts.vars)
+		encoder.SetPostProcessing(postProcessing)
+		encoders = append(encoders, encoder)
+	}
+
+	// Generate the encoders
+	templateDir, debug, destinationDir, index := templates.Dir(), opts.debug, opts.destinationDir, opts.index
+	var files []*descriptor.FileDescriptorProto
+	for _, file := range g.Request.GetProtoFile() {
+		if generates(file) {
+			files = append(files, file)
+		}
+	}
+	for _, s := range scopes {
+		// the aggregation scopes render the files to generate in a single pass, along with the imported files
+		// when include_imports is set, so their encoders are not dependencies
+		dependency = false
+		switch s {
+		case helpers.ScopeRequest:
+			templateIndex := index
+			if index == -1 {
+				templateIndex = 0
+			}
+			render(helpers.NewGenericRequestTemplateBasedEncoder(templateDir, files, debug, destinationDir, templateIndex))
+			continue
+		case helpers.ScopePackage:
+			packages, groups := helpers.PackageFiles(files)
+			for i, pkg := range packages {
+				templateIndex := index
+				if index == -1 {
+					templateIndex = i
+				}
+				render(helpers.NewGenericPackageTemplateBasedEncoder(templateDir, pkg, groups[pkg], debug, destinationDir, templateIndex))
+			}
+			continue
+		}
+
+		for baseIndex, file := range g.Request.GetProtoFile() {
+			templateIndex := index
+			if index == -1 {
+				templateIndex = baseIndex
+			}
+			if !generates(file) {
+				continue
+			}
+			dependency = !toGenerate[file.GetName()]
+			switch s {
+			case helpers.ScopeService:
+				for _, service := range file.GetService() {
+					render(helpers.NewGenericServiceTemplateBasedEncoder(templateDir, service, file, debug, destinationDir, templateIndex))
+				}
+			case helpers.ScopeFile:
+				if !opts.all && opts.fileMode && len(file.GetService()) == 0 {
+					continue
+				}
+				if opts.all && opts.singlePackageMode {
+					if _, err := registry.LookupFile(file.GetName()); err != nil {
+						*errs = append(*errs, fmt.Errorf("registry: failed to lookup file %q: %w", file.GetName(), err))
+						continue
+					}
+				}
+				render(helpers.NewGenericTemplateBasedEncoder(templateDir, file, debug, destinationDir, templateIndex))
+			case helpers.ScopeMessage:
+				for _, message := range helpers.AllMessages(file) {
+					render(helpers.NewGenericMessageTemplateBasedEncoder(templateDir, message, file, debug, destinationDir, templateIndex))
+				}
+			case helpers.ScopeEnum:
+				for _, enum := range helpers.AllEnums(file) {
+					render(helpers.NewGenericEnumTemplateBasedEncoder(templateDir, enum, file, debug, destinationDir, templateIndex))
+				}
+			case helpers.ScopeMethod:
+				for _, service := range file.GetService() {
+					for _, method := range service.GetMethod() {
+						render(helpers.NewGenericMethodTemplateBasedEncoder(templateDir, method, service, file, debug, destinationDir, templateIndex))
+					}
+				}
+			}
+		}
+	}
+
+	return encoders
+}
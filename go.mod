@@ -3,6 +3,7 @@ module github.com/chrismoran-blockfi/protoc-gen-gotemplate
 go 1.18
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	github.com/Masterminds/sprig v2.22.0+incompatible
 	github.com/gobuffalo/packr/v2 v2.8.0
 	github.com/golang/glog v1.0.0
@@ -10,10 +11,12 @@ require (
 	github.com/gorilla/handlers v1.5.1
 	github.com/gorilla/mux v1.8.0
 	github.com/huandu/xstrings v1.3.2
+	github.com/pmezard/go-difflib v1.0.0
 	golang.org/x/exp v0.0.0-20220516143420-24438e51023a
 	golang.org/x/net v0.0.0-20211112202133-69e39bad7dc2
 	google.golang.org/genproto v0.0.0-20220304144024-325a89244dc8
 	google.golang.org/protobuf v1.27.1
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
 
 require (
@@ -35,5 +38,4 @@ require (
 	golang.org/x/sys v0.0.0-20211019181941-9d821ace8654 // indirect
 	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
 )
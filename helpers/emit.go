@@ -0,0 +1,167 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	tmpl "text/template"
+
+	plugingo "google.golang.org/protobuf/types/pluginpb"
+)
+
+// emitter collects the additional outputs produced by a single template execution, through:
+//
+//	{{file "path/{{.Name}}.go" .}}...{{endfile}}
+//	{{emit "path/index.ts" $content}}
+//
+// Output paths are rendered as templates, with the given data or the Ast, and may carry an
+// insertion point using the "name@insertion_point" syntax of the template filenames.
+type emitter struct {
+	ast     *Ast
+	buffer  *bytes.Buffer
+	outputs []*plugingo.CodeGeneratorResponse_File
+	start   int
+	current string
+	// funcs override the helpers of the output paths.
+	funcs tmpl.FuncMap
+	// paths caches the parsed output paths, it is shared by the executions of a template.
+	paths *sync.Map
+}
+
+func newEmitter(buffer *bytes.Buffer, funcs tmpl.FuncMap, paths *sync.Map) *emitter {
+	return &emitter{
+		buffer: buffer,
+		start:  -1,
+		funcs:  funcs,
+		paths:  paths,
+	}
+}
+
+// reset prepares the emitter for a new execution writing to buffer.
+func (em *emitter) reset(buffer *bytes.Buffer) {
+	em.ast = nil
+	em.buffer = buffer
+	em.outputs = nil
+	em.start = -1
+	em.current = ""
+}
+
+func (em *emitter) funcMap() tmpl.FuncMap {
+	return tmpl.FuncMap{
+		"file":    em.file,
+		"endfile": em.endfile,
+		"emit":    em.emit,
+	}
+}
+
+// file starts a block whose rendered content is moved to a separate output.
+func (em *emitter) file(name string, data ...interface{}) (string, error) {
+	if em.start >= 0 {
+		return "", fmt.Errorf("file %q: missing endfile for %q", name, em.current)
+	}
+	path, err := em.path(name, data)
+	if err != nil {
+		return "", err
+	}
+	em.current = path
+	em.start = em.buffer.Len()
+	return "", nil
+}
+
+// endfile ends the block started by file.
+func (em *emitter) endfile() (string, error) {
+	if em.start < 0 {
+		return "", fmt.Errorf("endfile without file")
+	}
+	content := string(em.buffer.Bytes()[em.start:])
+	em.buffer.Truncate(em.start)
+	em.add(em.current, content)
+	em.start = -1
+	em.current = ""
+	return "", nil
+}
+
+// emit adds an output with the given content.
+func (em *emitter) emit(name string, content string) (string, error) {
+	path, err := em.path(name, nil)
+	if err != nil {
+		return "", err
+	}
+	em.add(path, content)
+	return "", nil
+}
+
+// within runs render with the file blocks recorded in buffer, which include and tpl render into
+// instead of the buffer of the execution. The file blocks started by render must be ended by it.
+func (em *emitter) within(buffer *bytes.Buffer, render func() error) error {
+	saved, start, current := em.buffer, em.start, em.current
+	em.buffer, em.start, em.current = buffer, -1, ""
+	err := render()
+	if err == nil {
+		err = em.close()
+	}
+	em.buffer, em.start, em.current = saved, start, current
+	return err
+}
+
+// close checks that every file block has been ended.
+func (em *emitter) close() error {
+	if em.start >= 0 {
+		return fmt.Errorf("file %q: missing endfile", em.current)
+	}
+	return nil
+}
+
+func (em *emitter) path(name string, data []interface{}) (string, error) {
+	if !strings.Contains(name, "{{") {
+		return name, nil
+	}
+	var dot interface{} = em.ast
+	if len(data) > 0 {
+		dot = data[0]
+	}
+	t, err := em.pathTemplate(name)
+	if err != nil {
+		return "", err
+	}
+	buffer := new(bytes.Buffer)
+	if err := t.Execute(buffer, dot); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// pathTemplate returns the parsed template of an output path, parsing it on its first use.
+func (em *emitter) pathTemplate(name string) (*tmpl.Template, error) {
+	if t, ok := em.paths.Load(name); ok {
+		return t.(*tmpl.Template), nil
+	}
+	t, err := tmpl.New(name).Funcs(ProtoHelpersFuncMap).Funcs(em.funcs).Parse(name)
+	if err != nil {
+		return nil, err
+	}
+	em.paths.Store(name, t)
+	return t, nil
+}
+
+func (em *emitter) add(path string, content string) {
+	file := &plugingo.CodeGeneratorResponse_File{
+		Content: &content,
+	}
+	name, insertionPoint := splitInsertionPoint(path)
+	file.Name = &name
+	if insertionPoint != "" {
+		file.InsertionPoint = &insertionPoint
+	}
+	em.outputs = append(em.outputs, file)
+}
+
+// splitInsertionPoint splits an output path of the form name@insertion_point,
+// as given to emit, file and the output of a front matter.
+func splitInsertionPoint(path string) (name string, insertionPoint string) {
+	if i := strings.Index(path, "@"); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return path, ""
+}
@@ -2,19 +2,61 @@ package helpers
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"log"
-	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
-	tmpl "text/template"
+	"sync"
 	"time"
 
 	descriptor "google.golang.org/protobuf/types/descriptorpb"
 	plugingo "google.golang.org/protobuf/types/pluginpb"
 )
 
+// errSkipTemplate is returned when a template must not produce any output,
+// either because its front matter condition is false or because it called the skip helper.
+var errSkipTemplate = errors.New("template skipped")
+
+// buildEnv describes the environment of the run, it is computed once.
+type buildEnv struct {
+	hostname string
+	user     string
+	pwd      string
+	goPwd    string
+}
+
+var (
+	buildEnvOnce sync.Once
+	buildEnvVal  buildEnv
+	buildEnvErr  error
+)
+
+func loadBuildEnv() (buildEnv, error) {
+	buildEnvOnce.Do(func() {
+		env := buildEnv{user: os.Getenv("USER")}
+		if env.hostname, buildEnvErr = os.Hostname(); buildEnvErr != nil {
+			return
+		}
+		if env.pwd, buildEnvErr = os.Getwd(); buildEnvErr != nil {
+			return
+		}
+		if os.Getenv("GOPATH") != "" {
+			if env.goPwd, buildEnvErr = filepath.Rel(os.Getenv("GOPATH")+"/src", env.pwd); buildEnvErr != nil {
+				return
+			}
+			if strings.Contains(env.goPwd, "../") {
+				env.goPwd = ""
+			}
+		}
+		buildEnvVal = env
+	})
+	return buildEnvVal, buildEnvErr
+}
+
 type ResponseSorter []*plugingo.CodeGeneratorResponse_File
 
 func (a ResponseSorter) Len() int {
@@ -22,8 +64,13 @@ func (a ResponseSorter) Len() int {
 }
 
 func (a ResponseSorter) Less(i, j int) bool {
-	nameCmp := strings.Compare(a[i].GetName(), a[j].GetName())
-	return nameCmp < 0 || nameCmp == 0 && len(a[i].GetInsertionPoint()) == 0
+	return responseFileLess(a[i], a[j])
+}
+
+// responseFileLess orders the files by name, a file coming before the insertions into it.
+func responseFileLess(a, b *plugingo.CodeGeneratorResponse_File) bool {
+	nameCmp := strings.Compare(a.GetName(), b.GetName())
+	return nameCmp < 0 || nameCmp == 0 && len(a.GetInsertionPoint()) == 0 && len(b.GetInsertionPoint()) > 0
 }
 
 func (a ResponseSorter) Swap(i, j int) {
@@ -51,6 +98,21 @@ type GenericTemplateBasedEncoder struct {
 	service        *descriptor.ServiceDescriptorProto
 	file           *descriptor.FileDescriptorProto
 	enum           []*descriptor.EnumDescriptorProto
+	message        *descriptor.DescriptorProto
+	enumType       *descriptor.EnumDescriptorProto
+	method         *descriptor.MethodDescriptorProto
+	pkg            string
+	files          []*descriptor.FileDescriptorProto
+	scope          Scope
+	defaultScope   Scope
+	dropEmpty      bool
+	dependency     bool
+	reproducible   bool
+	postProcessing PostProcessing
+	header         *Header
+	buildDate      time.Time
+	vars           map[string]interface{}
+	set            *TemplateSet
 	debug          bool
 	destinationDir string
 	index          int
@@ -59,93 +121,207 @@ type GenericTemplateBasedEncoder struct {
 }
 
 type Ast struct {
-	BuildDate      time.Time                          `json:"build-date"`
-	BuildHostname  string                             `json:"build-hostname"`
-	BuildUser      string                             `json:"build-user"`
-	GoPWD          string                             `json:"go-pwd,omitempty"`
-	PWD            string                             `json:"pwd"`
-	Debug          bool                               `json:"debug"`
-	DestinationDir string                             `json:"destination-dir"`
-	File           *descriptor.FileDescriptorProto    `json:"file"`
-	RawFilename    string                             `json:"raw-filename"`
-	Filename       string                             `json:"filename"`
-	TemplateDir    string                             `json:"template-dir"`
-	Service        *descriptor.ServiceDescriptorProto `json:"service"`
-	Enum           []*descriptor.EnumDescriptorProto  `json:"enum"`
-	Index          int                                `json:"index"`
+	BuildDate      time.Time                            `json:"build-date"`
+	BuildHostname  string                               `json:"build-hostname"`
+	BuildUser      string                               `json:"build-user"`
+	GoPWD          string                               `json:"go-pwd,omitempty"`
+	PWD            string                               `json:"pwd"`
+	Debug          bool                                 `json:"debug"`
+	DestinationDir string                               `json:"destination-dir"`
+	File           *descriptor.FileDescriptorProto      `json:"file"`
+	RawFilename    string                               `json:"raw-filename"`
+	Filename       string                               `json:"filename"`
+	TemplateDir    string                               `json:"template-dir"`
+	Service        *descriptor.ServiceDescriptorProto   `json:"service"`
+	Enum           []*descriptor.EnumDescriptorProto    `json:"enum"`
+	Message        *descriptor.DescriptorProto          `json:"message,omitempty"`
+	EnumType       *descriptor.EnumDescriptorProto      `json:"enum-type,omitempty"`
+	Method         *descriptor.MethodDescriptorProto    `json:"method,omitempty"`
+	Package        string                               `json:"package,omitempty"`
+	Files          []*descriptor.FileDescriptorProto    `json:"files,omitempty"`
+	Services       []*descriptor.ServiceDescriptorProto `json:"services,omitempty"`
+	Messages       []*descriptor.DescriptorProto        `json:"messages,omitempty"`
+	Enums          []*descriptor.EnumDescriptorProto    `json:"enums,omitempty"`
+	Vars           map[string]interface{}               `json:"vars,omitempty"`
+	Index          int                                  `json:"index"`
+	IsDependency   bool                                 `json:"is-dependency"`
+	Generate       bool                                 `json:"generate"`
 }
 
-func NewGenericServiceTemplateBasedEncoder(templateDir string, service *descriptor.ServiceDescriptorProto, file *descriptor.FileDescriptorProto, debug bool, destinationDir string, index int) (e *GenericTemplateBasedEncoder) {
-	e = &GenericTemplateBasedEncoder{
-		service:        service,
+func newGenericTemplateBasedEncoder(scope Scope, templateDir string, file *descriptor.FileDescriptorProto, debug bool, destinationDir string, index int) *GenericTemplateBasedEncoder {
+	e := &GenericTemplateBasedEncoder{
+		scope:          scope,
 		file:           file,
 		templateDir:    templateDir,
+		enum:           file.GetEnumType(),
 		debug:          debug,
 		destinationDir: destinationDir,
-		enum:           file.GetEnumType(),
 		index:          index,
 		directivesMap:  make(map[interface{}][]CommentDirective),
 	}
+	parseDirectives(&e.directivesMap, loadComments(file))
+	return e
+}
+
+func NewGenericServiceTemplateBasedEncoder(templateDir string, service *descriptor.ServiceDescriptorProto, file *descriptor.FileDescriptorProto, debug bool, destinationDir string, index int) (e *GenericTemplateBasedEncoder) {
+	e = newGenericTemplateBasedEncoder(ScopeService, templateDir, file, debug, destinationDir, index)
+	e.service = service
 	if debug {
 		log.Printf("new encoder: file=%q service=%q template-dir=%q", file.GetName(), service.GetName(), templateDir)
 	}
-	LoadComments(file)
-	parseDirectives(&e.directivesMap)
 	return
 }
 
 func NewGenericTemplateBasedEncoder(templateDir string, file *descriptor.FileDescriptorProto, debug bool, destinationDir string, index int) (e *GenericTemplateBasedEncoder) {
-	e = &GenericTemplateBasedEncoder{
-		service:        nil,
-		file:           file,
+	e = newGenericTemplateBasedEncoder(ScopeFile, templateDir, file, debug, destinationDir, index)
+	if debug {
+		log.Printf("new encoder: file=%q template-dir=%q", file.GetName(), templateDir)
+	}
+	return
+}
+
+// NewGenericMessageTemplateBasedEncoder returns an encoder rendering the templates for a single message.
+func NewGenericMessageTemplateBasedEncoder(templateDir string, message *descriptor.DescriptorProto, file *descriptor.FileDescriptorProto, debug bool, destinationDir string, index int) (e *GenericTemplateBasedEncoder) {
+	e = newGenericTemplateBasedEncoder(ScopeMessage, templateDir, file, debug, destinationDir, index)
+	e.message = message
+	if debug {
+		log.Printf("new encoder: file=%q message=%q template-dir=%q", file.GetName(), message.GetName(), templateDir)
+	}
+	return
+}
+
+// NewGenericEnumTemplateBasedEncoder returns an encoder rendering the templates for a single enum.
+func NewGenericEnumTemplateBasedEncoder(templateDir string, enum *descriptor.EnumDescriptorProto, file *descriptor.FileDescriptorProto, debug bool, destinationDir string, index int) (e *GenericTemplateBasedEncoder) {
+	e = newGenericTemplateBasedEncoder(ScopeEnum, templateDir, file, debug, destinationDir, index)
+	e.enumType = enum
+	if debug {
+		log.Printf("new encoder: file=%q enum=%q template-dir=%q", file.GetName(), enum.GetName(), templateDir)
+	}
+	return
+}
+
+// NewGenericMethodTemplateBasedEncoder returns an encoder rendering the templates for a single RPC method.
+func NewGenericMethodTemplateBasedEncoder(templateDir string, method *descriptor.MethodDescriptorProto, service *descriptor.ServiceDescriptorProto, file *descriptor.FileDescriptorProto, debug bool, destinationDir string, index int) (e *GenericTemplateBasedEncoder) {
+	e = newGenericTemplateBasedEncoder(ScopeMethod, templateDir, file, debug, destinationDir, index)
+	e.service = service
+	e.method = method
+	if debug {
+		log.Printf("new encoder: file=%q service=%q method=%q template-dir=%q", file.GetName(), service.GetName(), method.GetName(), templateDir)
+	}
+	return
+}
+
+// NewGenericPackageTemplateBasedEncoder returns an encoder rendering the templates once for every file of a proto package.
+// The files, services, messages and enums of the package are exposed on the Ast; Ast.File is nil.
+func NewGenericPackageTemplateBasedEncoder(templateDir string, pkg string, files []*descriptor.FileDescriptorProto, debug bool, destinationDir string, index int) (e *GenericTemplateBasedEncoder) {
+	e = newGenericGroupTemplateBasedEncoder(ScopePackage, templateDir, files, debug, destinationDir, index)
+	e.pkg = pkg
+	if debug {
+		log.Printf("new encoder: package=%q files=%d template-dir=%q", pkg, len(files), templateDir)
+	}
+	return
+}
+
+// NewGenericRequestTemplateBasedEncoder returns an encoder rendering the templates once for every file of the request.
+// The files, services, messages and enums of the request are exposed on the Ast; Ast.File is nil.
+func NewGenericRequestTemplateBasedEncoder(templateDir string, files []*descriptor.FileDescriptorProto, debug bool, destinationDir string, index int) (e *GenericTemplateBasedEncoder) {
+	e = newGenericGroupTemplateBasedEncoder(ScopeRequest, templateDir, files, debug, destinationDir, index)
+	if debug {
+		log.Printf("new encoder: files=%d template-dir=%q", len(files), templateDir)
+	}
+	return
+}
+
+func newGenericGroupTemplateBasedEncoder(scope Scope, templateDir string, files []*descriptor.FileDescriptorProto, debug bool, destinationDir string, index int) *GenericTemplateBasedEncoder {
+	e := &GenericTemplateBasedEncoder{
+		scope:          scope,
 		templateDir:    templateDir,
-		enum:           file.GetEnumType(),
+		files:          files,
 		debug:          debug,
 		destinationDir: destinationDir,
 		index:          index,
 		directivesMap:  make(map[interface{}][]CommentDirective),
 	}
-	if debug {
-		log.Printf("new encoder: file=%q template-dir=%q", file.GetName(), templateDir)
+	for _, file := range files {
+		e.enum = append(e.enum, file.GetEnumType()...)
 	}
-	LoadComments(file)
-	parseDirectives(&e.directivesMap)
+	parseDirectives(&e.directivesMap, loadComments(files...))
+	return e
+}
 
-	return
+// SetDefaultScope sets the scope of the run.
+// Templates without a front matter scope are only rendered by encoders of that scope,
+// templates declaring a scope are only rendered by encoders of the declared scope.
+// When no default scope is set, templates without a front matter scope are always rendered.
+func (e *GenericTemplateBasedEncoder) SetDefaultScope(scope Scope) {
+	e.defaultScope = scope
 }
 
-type template struct {
-	fileName       string
-	content        string
-	insertionPoint string
+// SetDropEmpty makes the encoder drop the outputs whose content is only made of whitespaces.
+func (e *GenericTemplateBasedEncoder) SetDropEmpty(dropEmpty bool) {
+	e.dropEmpty = dropEmpty
 }
 
-func (e *GenericTemplateBasedEncoder) templates() ([]template, error) {
-	templates := make([]template, 0)
+// SetDependency marks the file of the encoder as imported by the files to generate, rather than one of them.
+func (e *GenericTemplateBasedEncoder) SetDependency(dependency bool) {
+	e.dependency = dependency
+}
 
-	err := filepath.Walk(e.templateDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			return nil
-		}
-		if filepath.Ext(path) != ".tmpl" {
-			return nil
-		}
-		rel, err := filepath.Rel(e.templateDir, path)
+// SetReproducible makes the outputs independent of the time and the machine of the run:
+// BuildDate is set to buildDate and the host and user specific fields of the Ast are left empty.
+func (e *GenericTemplateBasedEncoder) SetReproducible(buildDate time.Time) {
+	e.reproducible = true
+	e.buildDate = buildDate
+}
+
+// SetPostProcessing sets the post-processors applied to the outputs, insertions excepted.
+func (e *GenericTemplateBasedEncoder) SetPostProcessing(postProcessing PostProcessing) {
+	e.postProcessing = postProcessing
+}
+
+// SetHeader sets the header prepended to the outputs.
+func (e *GenericTemplateBasedEncoder) SetHeader(header *Header) {
+	e.header = header
+}
+
+// SetVars sets the variables of the run, exposed to the templates as .Vars.
+// The variables of a template front matter override them.
+func (e *GenericTemplateBasedEncoder) SetVars(vars map[string]interface{}) {
+	e.vars = vars
+}
+
+// SetTemplateSet makes the encoder render the templates of a set loaded once for the whole run,
+// instead of loading the templates of its template directory.
+func (e *GenericTemplateBasedEncoder) SetTemplateSet(set *TemplateSet) {
+	e.set = set
+}
+
+// renders reports whether the encoder renders the given template.
+func (e *GenericTemplateBasedEncoder) renders(t *template) bool {
+	if scope := t.scope(); scope != "" {
+		return scope == e.scope
+	}
+	return e.defaultScope == "" || e.defaultScope == e.scope
+}
+
+func (e *GenericTemplateBasedEncoder) templates() ([]*template, error) {
+	if e.set == nil {
+		set, err := LoadTemplateSet(e.templateDir, Filter{}, nil, e.debug)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		if e.debug {
-			log.Printf("new template: %q", rel)
+		e.set = set
+	}
+	templates := make([]*template, 0, len(e.set.templates))
+	for _, t := range e.set.templates {
+		if e.renders(t) {
+			templates = append(templates, t)
 		}
-
-		templates = append(templates, template{
-			fileName: rel,
-		})
-		return nil
-	})
+	}
+	if e.defaultScope != "" && e.defaultScope != e.scope {
+		return templates, nil
+	}
+	var inserts []*template
 	for _, dirs := range e.directivesMap {
 		for _, dir := range dirs {
 			if dir.Directive == "protoc_insert" {
@@ -153,7 +329,7 @@ func (e *GenericTemplateBasedEncoder) templates() ([]template, error) {
 				name := params[0]
 				insert := params[1]
 				content := dir.Value
-				templates = append(templates, template{
+				inserts = append(inserts, &template{
 					fileName:       name,
 					content:        content,
 					insertionPoint: insert,
@@ -161,35 +337,39 @@ func (e *GenericTemplateBasedEncoder) templates() ([]template, error) {
 			}
 		}
 	}
-	return templates, err
+	// directives are collected from a map, they are sorted to render them in a deterministic order
+	sort.SliceStable(inserts, func(i, j int) bool {
+		a, b := inserts[i], inserts[j]
+		if a.fileName != b.fileName {
+			return a.fileName < b.fileName
+		}
+		if a.insertionPoint != b.insertionPoint {
+			return a.insertionPoint < b.insertionPoint
+		}
+		return a.content < b.content
+	})
+	for _, t := range inserts {
+		if err := e.set.parse(t); err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
 }
 
-func (e *GenericTemplateBasedEncoder) genAst(templateFilename string) (*Ast, error) {
+func (e *GenericTemplateBasedEncoder) genAst(tmplt *template) (*Ast, error) {
+	templateFilename := tmplt.fileName
 	// prepare the ast passed to the template engine
-	hostname, err := os.Hostname()
-	if err != nil {
-		return nil, err
-	}
-	pwd, err := os.Getwd()
+	env, err := loadBuildEnv()
 	if err != nil {
 		return nil, err
 	}
-	goPwd := ""
-	if os.Getenv("GOPATH") != "" {
-		goPwd, err = filepath.Rel(os.Getenv("GOPATH")+"/src", pwd)
-		if err != nil {
-			return nil, err
-		}
-		if strings.Contains(goPwd, "../") {
-			goPwd = ""
-		}
-	}
 	ast := Ast{
 		BuildDate:      time.Now(),
-		BuildHostname:  hostname,
-		BuildUser:      os.Getenv("USER"),
-		PWD:            pwd,
-		GoPWD:          goPwd,
+		BuildHostname:  env.hostname,
+		BuildUser:      env.user,
+		PWD:            env.pwd,
+		GoPWD:          env.goPwd,
 		File:           e.file,
 		TemplateDir:    e.templateDir,
 		DestinationDir: e.destinationDir,
@@ -197,116 +377,331 @@ func (e *GenericTemplateBasedEncoder) genAst(templateFilename string) (*Ast, err
 		Filename:       "",
 		Service:        e.service,
 		Enum:           e.enum,
+		Message:        e.message,
+		EnumType:       e.enumType,
+		Method:         e.method,
+		Package:        e.pkg,
+		Files:          e.files,
 		Index:          e.index,
+		IsDependency:   e.dependency,
+		Generate:       !e.dependency,
 	}
-	buffer := new(bytes.Buffer)
-
-	unescaped, err := url.QueryUnescape(templateFilename)
-	if err != nil {
-		log.Printf("failed to unescape filepath %q: %v", templateFilename, err)
-	} else {
-		templateFilename = unescaped
+	if e.reproducible {
+		ast.BuildDate = e.buildDate
+		ast.BuildHostname, ast.BuildUser, ast.PWD, ast.GoPWD = "", "", "", ""
 	}
-
-	templateFile, err := tmpl.New("").Funcs(ProtoHelpersFuncMap).Parse(templateFilename)
-	if err != nil {
-		return nil, err
+	for _, file := range e.files {
+		ast.Services = append(ast.Services, file.GetService()...)
+		ast.Messages = append(ast.Messages, AllMessages(file)...)
+		ast.Enums = append(ast.Enums, AllEnums(file)...)
 	}
-	if err := templateFile.Execute(buffer, ast); err != nil {
+	ast.Vars = e.vars
+	if tmplt.frontMatter != nil && len(tmplt.frontMatter.Vars) > 0 {
+		ast.Vars = make(map[string]interface{}, len(e.vars)+len(tmplt.frontMatter.Vars))
+		for name, value := range e.vars {
+			ast.Vars[name] = value
+		}
+		for name, value := range tmplt.frontMatter.Vars {
+			ast.Vars[name] = value
+		}
+	}
+	buffer := new(bytes.Buffer)
+	if err := tmplt.filename.Execute(buffer, ast); err != nil {
 		return nil, err
 	}
 	ast.Filename = buffer.String()
 	return &ast, nil
 }
 
-func (e *GenericTemplateBasedEncoder) buildContent(tmplt template) (string, string, error) {
-	// initialize template engine
-	if tmplt.content == "" {
+// buildContent renders a template, it returns the content of the main output and the Ast it was rendered with,
+// along with the additional outputs emitted by the template.
+func (e *GenericTemplateBasedEncoder) buildContent(tmplt *template) (string, *Ast, []*plugingo.CodeGeneratorResponse_File, error) {
+	buffer := new(bytes.Buffer)
+	ex, err := tmplt.acquire(buffer)
+	if err != nil {
+		return "", nil, nil, err
 	}
-	templateFilename := tmplt.fileName
-	fullPath := filepath.Join(e.templateDir, templateFilename)
-	templateName := filepath.Base(fullPath)
+	defer tmplt.release(ex)
+	templateFile, em := ex.template, ex.emitter
 
-	templateFile := tmpl.New(templateName).Funcs(ProtoHelpersFuncMap)
-	var terr error
-	if tmplt.content == "" {
-		templateFile, terr = templateFile.ParseFiles(fullPath)
-	} else {
-		templateFile, terr = templateFile.Parse(tmplt.content)
-	}
-	if terr != nil {
-		return "", "", terr
+	ast, err := e.genAst(tmplt)
+	if err != nil {
+		return "", nil, nil, err
 	}
+	em.ast = ast
 
-	ast, err := e.genAst(templateFilename)
+	ok, err := evalWhen(tmplt.when, ast)
 	if err != nil {
-		return "", "", err
+		return "", nil, nil, err
+	}
+	if !ok {
+		return "", nil, nil, errSkipTemplate
 	}
 
 	// generate the content
-	buffer := new(bytes.Buffer)
 	if err := templateFile.Execute(buffer, ast); err != nil {
-		return "", "", err
+		return "", nil, nil, err
+	}
+	if err := em.close(); err != nil {
+		return "", nil, nil, fmt.Errorf("%s: %w", templateFile.Name(), err)
 	}
 
-	return buffer.String(), ast.Filename, nil
+	return buffer.String(), ast, em.outputs, nil
 }
 
+// Files renders the templates of the encoder.
+//
+// Deprecated: Files panics on the first error, use Render instead.
 func (e *GenericTemplateBasedEncoder) Files() []*plugingo.CodeGeneratorResponse_File {
-	templates, err := e.templates()
+	files, err := e.Render()
 	if err != nil {
-		log.Fatalf("cannot get templates from %q: %v", e.templateDir, err)
+		panic(err)
 	}
+	return files
+}
+
+// Render renders the templates of the encoder.
+// Every failing template is reported, the returned error is then an Errors of *TemplateError.
+func (e *GenericTemplateBasedEncoder) Render() ([]*plugingo.CodeGeneratorResponse_File, error) {
+	files, err := RenderAll([]*GenericTemplateBasedEncoder{e}, 0)
+	if err != nil {
+		return nil, err
+	}
+	return files[0], nil
+}
 
-	length := len(templates)
-	files := make([]*plugingo.CodeGeneratorResponse_File, 0, length)
-	errChan := make(chan error, length)
-	resultChan := make(chan *plugingo.CodeGeneratorResponse_File, length)
+// RenderAll renders the templates of every encoder, with a pool of at most jobs workers
+// shared by all the (encoder, template) pairs; jobs <= 0 means GOMAXPROCS.
+// The files are returned per encoder, in the order of the encoders and of their templates,
+// whatever the order in which the renderings complete. Every failing template is reported,
+// the returned error is then an Errors of *TemplateError.
+func RenderAll(encoders []*GenericTemplateBasedEncoder, jobs int) ([][]*plugingo.CodeGeneratorResponse_File, error) {
+	outputs, err := RenderOutputs(encoders, jobs)
+	files := make([][]*plugingo.CodeGeneratorResponse_File, len(outputs))
+	for i, encoderOutputs := range outputs {
+		for _, output := range encoderOutputs {
+			files[i] = append(files[i], output.CodeGeneratorResponse_File)
+		}
+	}
+	return files, err
+}
 
-	for _, templ := range templates {
-		go func(tmpl template) {
-			var translatedFilename, content, insertionPoint, filename string
+// Output is a file rendered by a template.
+type Output struct {
+	*plugingo.CodeGeneratorResponse_File
+	// Template is the path of the template.
+	Template string
+	// ProtoFile is the name of the proto file rendered, or the list of files for aggregation scopes.
+	ProtoFile string
+	// Element describes the element rendered, e.g. "message Foo".
+	Element string
+	// Header is the header rendered for the output, if any. It is not part of the content, so that
+	// the outputs concatenated into a single file get a single header, see PrependHeader.
+	Header string
+}
 
-			if strings.Contains(tmpl.fileName, "@") {
-				insertionPoint = tmpl.fileName[strings.Index(tmpl.fileName, "@")+1 : strings.Index(tmpl.fileName, ".tmpl")]
-			}
-			if tmpl.insertionPoint != "" {
-				insertionPoint = tmpl.insertionPoint
+// RenderOutputs is RenderAll, the files being returned along with the template and the element they were rendered for.
+func RenderOutputs(encoders []*GenericTemplateBasedEncoder, jobs int) ([][]*Output, error) {
+	type task struct {
+		encoder  int
+		template *template
+		outputs  []*Output
+		err      error
+	}
+	var tasks []*task
+	var errs Errors
+	for i, e := range encoders {
+		templates, err := e.templates()
+		if err != nil {
+			errs = append(errs, e.templateError(e.templateDir, err))
+			continue
+		}
+		for _, t := range templates {
+			tasks = append(tasks, &task{encoder: i, template: t})
+		}
+	}
+
+	if jobs <= 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	queue := make(chan *task)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs && w < len(tasks); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range queue {
+				t.outputs, t.err = encoders[t.encoder].render(t.template)
 			}
+		}()
+	}
+	for _, t := range tasks {
+		queue <- t
+	}
+	close(queue)
+	wg.Wait()
+
+	outputs := make([][]*Output, len(encoders))
+	for _, t := range tasks {
+		e := encoders[t.encoder]
+		templatePath := filepath.Join(e.templateDir, t.template.fileName)
+		if t.err != nil {
+			errs = append(errs, e.templateError(templatePath, t.err))
+			continue
+		}
+		for _, o := range t.outputs {
+			o.Template, o.ProtoFile, o.Element = templatePath, e.protoFile(), e.element()
+			outputs[t.encoder] = append(outputs[t.encoder], o)
+		}
+	}
+	for _, o := range outputs {
+		sort.SliceStable(o, func(i, j int) bool {
+			return responseFileLess(o[i].CodeGeneratorResponse_File, o[j].CodeGeneratorResponse_File)
+		})
+	}
+	if len(errs) > 0 {
+		return outputs, errs
+	}
+	return outputs, nil
+}
 
-			content, translatedFilename, err = e.buildContent(tmpl)
-			if err != nil {
-				errChan <- err
-				return
+// render renders a single template, along with the outputs it emits.
+func (e *GenericTemplateBasedEncoder) render(tmpl *template) ([]*Output, error) {
+	var insertionPoint, filename string
+
+	if strings.Contains(tmpl.fileName, "@") {
+		insertionPoint = tmpl.fileName[strings.Index(tmpl.fileName, "@")+1 : strings.Index(tmpl.fileName, ".tmpl")]
+	}
+	if tmpl.insertionPoint != "" {
+		insertionPoint = tmpl.insertionPoint
+	}
+
+	content, ast, emitted, err := e.buildContent(tmpl)
+	if errors.Is(err, errSkipTemplate) {
+		if e.debug {
+			log.Printf("skipping template %q: %v", tmpl.fileName, err)
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]*Output, 0, len(emitted)+1)
+	for _, f := range emitted {
+		if e.dropEmpty && strings.TrimSpace(f.GetContent()) == "" {
+			if e.debug {
+				log.Printf("dropping empty output %q of template %q", f.GetName(), tmpl.fileName)
 			}
-			if len(insertionPoint) > 0 && strings.Contains(tmpl.fileName, "@") {
-				filename = tmpl.fileName[:strings.Index(tmpl.fileName, "@")]
-			} else {
-				filename = translatedFilename[:len(translatedFilename)-len(".tmpl")]
+			continue
+		}
+		if f.InsertionPoint == nil {
+			if *f.Content, err = postProcess(e.postProcessors(tmpl, f.GetName()), f.GetContent()); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.GetName(), err)
 			}
+		}
+		header, err := e.renderHeader(f.GetName(), ast, tmpl, f.InsertionPoint != nil)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, &Output{CodeGeneratorResponse_File: f, Header: header})
+	}
+	// templates only made of file blocks do not produce a main output
+	if (e.dropEmpty || len(emitted) > 0) && strings.TrimSpace(content) == "" {
+		if e.debug {
+			log.Printf("dropping empty output of template %q", tmpl.fileName)
+		}
+		return outputs, nil
+	}
+	if len(insertionPoint) > 0 && strings.Contains(tmpl.fileName, "@") {
+		filename = tmpl.fileName[:strings.Index(tmpl.fileName, "@")]
+	} else if tmpl.frontMatter != nil && tmpl.frontMatter.Output != "" {
+		var point string
+		if filename, point = splitInsertionPoint(ast.Filename); point != "" {
+			insertionPoint = point
+		}
+	} else {
+		filename = ast.Filename[:len(ast.Filename)-len(".tmpl")]
+	}
 
-			if len(insertionPoint) > 0 {
-				resultChan <- &plugingo.CodeGeneratorResponse_File{
-					Content:        &content,
-					Name:           &filename,
-					InsertionPoint: &insertionPoint,
-				}
-			} else {
-				resultChan <- &plugingo.CodeGeneratorResponse_File{
-					Content: &content,
-					Name:    &filename,
-				}
-			}
-		}(templ)
-	}
-	for i := 0; i < length; i++ {
-		select {
-		case f := <-resultChan:
-			files = append(files, f)
-		case err = <-errChan:
-			panic(err)
+	header, err := e.renderHeader(filename, ast, tmpl, len(insertionPoint) > 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(insertionPoint) > 0 {
+		// insertions are fragments of another output, they are not post-processed
+		return append(outputs, &Output{
+			CodeGeneratorResponse_File: &plugingo.CodeGeneratorResponse_File{
+				Content:        &content,
+				Name:           &filename,
+				InsertionPoint: &insertionPoint,
+			},
+			Header: header,
+		}), nil
+	}
+	if content, err = postProcess(e.postProcessors(tmpl, filename), content); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return append(outputs, &Output{
+		CodeGeneratorResponse_File: &plugingo.CodeGeneratorResponse_File{
+			Content: &content,
+			Name:    &filename,
+		},
+		Header: header,
+	}), nil
+}
+
+// postProcessors returns the post-processors of an output of a template, the ones of its front matter
+// when it lists them.
+func (e *GenericTemplateBasedEncoder) postProcessors(tmpl *template, name string) []string {
+	if tmpl.frontMatter != nil && tmpl.frontMatter.PostProcess != nil {
+		return tmpl.frontMatter.PostProcess
+	}
+	return e.postProcessing.of(name)
+}
+
+// renderHeader renders the header, if any, of an output of a template.
+func (e *GenericTemplateBasedEncoder) renderHeader(name string, ast *Ast, tmpl *template, insertion bool) (string, error) {
+	if e.header == nil {
+		return "", nil
+	}
+	header, err := e.header.render(name, ast, e.protoFile(), filepath.Join(e.templateDir, tmpl.fileName), insertion)
+	if err != nil {
+		return "", fmt.Errorf("header: %w", err)
+	}
+	return header, nil
+}
+
+// element describes the protobuf element rendered by the encoder, for error messages.
+func (e *GenericTemplateBasedEncoder) element() string {
+	switch {
+	case e.method != nil:
+		return fmt.Sprintf("method %s.%s", e.service.GetName(), e.method.GetName())
+	case e.message != nil:
+		return fmt.Sprintf("message %s", e.message.GetName())
+	case e.enumType != nil:
+		return fmt.Sprintf("enum %s", e.enumType.GetName())
+	case e.service != nil:
+		return fmt.Sprintf("service %s", e.service.GetName())
+	case e.scope == ScopePackage:
+		return fmt.Sprintf("package %s", e.pkg)
+	case e.scope == ScopeRequest:
+		return "request"
+	}
+	return "file"
+}
+
+// protoFile returns the name of the proto file of the encoder, or the list of files for aggregation scopes.
+func (e *GenericTemplateBasedEncoder) protoFile() string {
+	if e.file == nil {
+		names := make([]string, len(e.files))
+		for i, f := range e.files {
+			names[i] = f.GetName()
 		}
+		return strings.Join(names, ", ")
 	}
-	sort.Sort(ResponseSorter(files))
-	return files
+	return e.file.GetName()
+}
+
+func (e *GenericTemplateBasedEncoder) templateError(templatePath string, err error) *TemplateError {
+	return newTemplateError(e.protoFile(), e.element(), templatePath, err)
 }
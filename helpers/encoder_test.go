@@ -0,0 +1,45 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	descriptor "google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestRenderFrontMatterOutputs(t *testing.T) {
+	dir := t.TempDir()
+	templates := map[string]string{
+		// the front matter post-processors apply to the emitted outputs too
+		"a.txt.tmpl": "---\npostprocess: [newlines]\n---\n{{emit \"b.txt\" \"b\\r\\n\\r\\n\"}}a\r\n",
+		"c.txt.tmpl": "---\noutput: \"a.txt@here\"\n---\nc",
+	}
+	for name, content := range templates {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := &descriptor.FileDescriptorProto{Name: proto.String("a.proto"), Package: proto.String("a")}
+	e := NewGenericTemplateBasedEncoder(dir, file, false, ".", 0)
+	files, err := e.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type output struct{ name, insertionPoint, content string }
+	want := []output{
+		{"a.txt", "", "a\n"},
+		{"a.txt", "here", "c"},
+		{"b.txt", "", "b\n"},
+	}
+	if len(files) != len(want) {
+		t.Fatalf("Render() returned %d files, want %d", len(files), len(want))
+	}
+	for i, f := range files {
+		if got := (output{f.GetName(), f.GetInsertionPoint(), f.GetContent()}); got != want[i] {
+			t.Errorf("Render() file %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
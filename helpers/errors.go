@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Errors is a list of errors reported at once.
+type Errors []error
+
+func (errs Errors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// TemplateError is an error raised while rendering a template for a protobuf element.
+type TemplateError struct {
+	// ProtoFile is the name of the proto file being rendered, or the list of files for aggregation scopes.
+	ProtoFile string
+	// Element describes the element being rendered, e.g. "message Foo".
+	Element string
+	// Template is the path of the template.
+	Template string
+	// Line and Column locate the error in the template, they are 0 when unknown.
+	Line   int
+	Column int
+	// Err is the error.
+	Err error
+
+	// msg is the message of Err without its location.
+	msg string
+}
+
+// templateErrorRe matches the location prefix of text/template errors, e.g. `template: foo.tmpl:3:12: `.
+var templateErrorRe = regexp.MustCompile(`^template: ([^:]+):(\d+)(?::(\d+))?: `)
+
+func newTemplateError(protoFile string, element string, templatePath string, err error) *TemplateError {
+	te := &TemplateError{
+		ProtoFile: protoFile,
+		Element:   element,
+		Template:  templatePath,
+		Err:       err,
+		msg:       err.Error(),
+	}
+	// the location is only extracted when the error is raised by the template itself, not by a partial
+	if m := templateErrorRe.FindStringSubmatch(te.msg); m != nil && m[1] == filepath.Base(templatePath) {
+		te.Line, _ = strconv.Atoi(m[2])
+		te.Column, _ = strconv.Atoi(m[3])
+		te.msg = strings.TrimPrefix(te.msg, m[0])
+	}
+	return te
+}
+
+func (e *TemplateError) Error() string {
+	location := e.Template
+	if e.Line > 0 {
+		location += ":" + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			location += ":" + strconv.Itoa(e.Column)
+		}
+	}
+	return fmt.Sprintf("%s: %s: %s: %s", e.ProtoFile, e.Element, location, e.msg)
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,141 @@
+package helpers
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile is the file of template_dir listing the files which are not templates.
+const IgnoreFile = ".gotemplateignore"
+
+// Filter selects slash separated paths with glob patterns, see MatchGlob.
+// A path is selected when it matches one of the Include patterns, or when there are none,
+// and none of the Exclude patterns.
+type Filter struct {
+	Include []string
+	Exclude []string
+}
+
+// Match reports whether the filter selects name.
+func (f Filter) Match(name string) bool {
+	if len(f.Include) > 0 && !matchAny(f.Include, name) {
+		return false
+	}
+	return !matchAny(f.Exclude, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if MatchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateGlob checks the syntax of a glob pattern.
+func ValidateGlob(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MatchGlob reports whether a slash separated path matches a glob pattern.
+// The pattern follows the syntax of path.Match, along with "**" segments matching any number of directories,
+// e.g. "google/**" or "**/*_test.proto".
+func MatchGlob(pattern string, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// ignoreRule is a pattern of an ignore file.
+type ignoreRule struct {
+	pattern string
+	dirOnly bool
+	// negate re-includes the paths ignored by the previous rules.
+	negate bool
+}
+
+// loadIgnoreFile reads the ignore file of a template directory, if any.
+// Like a .gitignore, it holds a pattern per line, "#" starting a comment; a pattern without a slash
+// matches at any depth, a pattern starting with a slash is relative to the template directory,
+// a pattern ending with a slash only matches directories and a pattern starting with "!" re-includes
+// the paths ignored by the previous lines. "\#" and "\!" start the patterns beginning with these characters.
+func loadIgnoreFile(templateDir string) ([]ignoreRule, error) {
+	f, err := os.Open(filepath.Join(templateDir, IgnoreFile)) // #nosec
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{pattern: line}
+		if strings.HasPrefix(rule.pattern, "!") {
+			rule.pattern = strings.TrimPrefix(rule.pattern, "!")
+			rule.negate = true
+		} else if strings.HasPrefix(rule.pattern, `\!`) || strings.HasPrefix(rule.pattern, `\#`) {
+			rule.pattern = rule.pattern[1:]
+		}
+		if strings.HasSuffix(rule.pattern, "/") {
+			rule.pattern = strings.TrimSuffix(rule.pattern, "/")
+			rule.dirOnly = true
+		}
+		if strings.HasPrefix(rule.pattern, "/") {
+			rule.pattern = strings.TrimPrefix(rule.pattern, "/")
+		} else if !strings.Contains(rule.pattern, "/") {
+			rule.pattern = "**/" + rule.pattern
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// ignored reports whether a path relative to the template directory is ignored, the last matching rule winning.
+func ignored(rules []ignoreRule, rel string, dir bool) bool {
+	rel = filepath.ToSlash(rel)
+	ignore := false
+	for _, rule := range rules {
+		if rule.dirOnly && !dir {
+			continue
+		}
+		if MatchGlob(rule.pattern, rel) {
+			ignore = !rule.negate
+		}
+	}
+	return ignore
+}
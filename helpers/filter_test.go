@@ -0,0 +1,163 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	for _, test := range []struct {
+		pattern, name string
+		match         bool
+	}{
+		{"a.proto", "a.proto", true},
+		{"a.proto", "b/a.proto", false},
+		{"*.proto", "a.proto", true},
+		{"*.proto", "b/a.proto", false},
+		{"b/*.proto", "b/a.proto", true},
+		{"b/*.proto", "b/c/a.proto", false},
+		{"b/?.proto", "b/a.proto", true},
+		{"b/[ab].proto", "b/c.proto", false},
+		{"**", "a.proto", true},
+		{"**", "b/c/a.proto", true},
+		{"**/*.proto", "a.proto", true},
+		{"**/*.proto", "b/c/a.proto", true},
+		{"**/*_test.proto", "b/a.proto", false},
+		{"google/**", "google/api/http.proto", true},
+		{"google/**", "google", true},
+		{"google/**", "googleapis/http.proto", false},
+		{"a/**/c.proto", "a/c.proto", true},
+		{"a/**/c.proto", "a/b/b/c.proto", true},
+		{"a/**/c.proto", "a/b/d.proto", false},
+		{"a/**/b/**/c", "a/x/b/y/z/c", true},
+		{"a/**/b/**/c", "a/x/y/z/c", false},
+		{"[", "[", false},
+	} {
+		if got := MatchGlob(test.pattern, test.name); got != test.match {
+			t.Errorf("MatchGlob(%q, %q) = %t, want %t", test.pattern, test.name, got, test.match)
+		}
+	}
+}
+
+func TestValidateGlob(t *testing.T) {
+	for pattern, valid := range map[string]bool{"**/*.proto": true, "a/[bc]/d": true, "a/[b": false, `a\`: false} {
+		if err := ValidateGlob(pattern); (err == nil) != valid {
+			t.Errorf("ValidateGlob(%q) = %v, want valid %t", pattern, err, valid)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	f := Filter{Include: []string{"api/**"}, Exclude: []string{"**/internal/**"}}
+	for name, match := range map[string]bool{
+		"api/a.proto":             true,
+		"api/v1/a.proto":          true,
+		"api/internal/a.proto":    false,
+		"other/a.proto":           false,
+		"api/v1/internal/a.proto": false,
+	} {
+		if got := f.Match(name); got != match {
+			t.Errorf("Match(%q) = %t, want %t", name, got, match)
+		}
+	}
+	if !(Filter{}).Match("any/thing") {
+		t.Errorf("the empty filter should select every path")
+	}
+}
+
+const testIgnoreFile = `# comment
+*.swp
+  *~.tmpl
+!keep~.tmpl
+/root.tmpl
+wip/
+docs/*.tmpl
+!docs/index.tmpl
+\#hash.tmpl
+\!bang.tmpl
+`
+
+func TestIgnored(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, IgnoreFile), []byte(testIgnoreFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := loadIgnoreFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		rel     string
+		dir     bool
+		ignored bool
+	}{
+		{"a.swp", false, true},
+		{"sub/deep/a.swp", false, true},
+		{"a~.tmpl", false, true},
+		{"sub/a~.tmpl", false, true},
+		// negation and ordering: the last matching rule wins
+		{"keep~.tmpl", false, false},
+		{"sub/keep~.tmpl", false, false},
+		{"docs/a.tmpl", false, true},
+		{"docs/index.tmpl", false, false},
+		{"docs/sub/a.tmpl", false, false},
+		// anchored patterns
+		{"root.tmpl", false, true},
+		{"sub/root.tmpl", false, false},
+		// directory patterns
+		{"wip", true, true},
+		{"sub/wip", true, true},
+		{"wip", false, false},
+		{"wip.tmpl", false, false},
+		// escapes
+		{"#hash.tmpl", false, true},
+		{"!bang.tmpl", false, true},
+		{"bang.tmpl", false, false},
+		{"comment", false, false},
+		{"a.tmpl", false, false},
+	} {
+		if got := ignored(rules, filepath.FromSlash(test.rel), test.dir); got != test.ignored {
+			t.Errorf("ignored(%q, dir=%t) = %t, want %t", test.rel, test.dir, got, test.ignored)
+		}
+	}
+}
+
+func TestLoadIgnoreFileMissing(t *testing.T) {
+	rules, err := loadIgnoreFile(t.TempDir())
+	if err != nil || rules != nil {
+		t.Errorf("loadIgnoreFile() = %v, %v, want no rules", rules, err)
+	}
+}
+
+func TestLoadTemplateSetIgnoreFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt.tmpl", "a.txt.tmpl.swp", "b~.tmpl", "keep~.tmpl", "wip/c.txt.tmpl", "wip/keep~.tmpl", "docs/d.tmpl", "docs/index.tmpl"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, IgnoreFile), []byte(testIgnoreFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	set, err := LoadTemplateSet(dir, Filter{}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tmpl := range set.templates {
+		names = append(names, filepath.ToSlash(tmpl.fileName))
+	}
+	sort.Strings(names)
+	// the files of an ignored directory are skipped, as with git
+	want := []string{"a.txt.tmpl", "docs/index.tmpl", "keep~.tmpl"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("templates = %q, want %q", names, want)
+	}
+}
@@ -0,0 +1,127 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	tmpl "text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+const frontMatterDelimiter = "---"
+
+// FrontMatter is the optional YAML block starting a template file, e.g.:
+//
+//	---
+//	scope: message
+//	output: "{{.File.Package}}/{{.Message.Name}}.ts"
+//	when: gt (len .Message.Field) 0
+//	vars:
+//	  prefix: api
+//	postprocess: [gofmt]
+//	---
+type FrontMatter struct {
+	// Scope overrides the scope the template is rendered for.
+	Scope Scope `yaml:"scope"`
+	// Output is a template rendered with the Ast giving the output path, it replaces the template filename.
+	// As with emit, an output path of the form name@insertion_point is an insertion.
+	Output string `yaml:"output"`
+	// When is a template pipeline evaluated with the Ast, the template is skipped when it is false or empty.
+	When string `yaml:"when"`
+	// Vars are exposed to the template as .Vars.
+	Vars map[string]interface{} `yaml:"vars"`
+	// PostProcess overrides the post-processors of the outputs of the template, an empty list disables them.
+	PostProcess []string `yaml:"postprocess"`
+}
+
+// frontMatterKeys are the keys of a front matter, a block defining none of them is not a front matter.
+var frontMatterKeys = map[string]bool{"scope": true, "output": true, "when": true, "vars": true, "postprocess": true}
+
+// splitFrontMatter extracts the front matter from the content of a template.
+// The front matter is replaced by a template comment spanning the same lines,
+// so that line numbers reported by text/template still match the original file.
+// A leading block which is not a YAML mapping of front matter keys, such as the first document
+// of a YAML template, is left in the template.
+func splitFrontMatter(content string) (*FrontMatter, string, error) {
+	firstLine, rest, found := strings.Cut(content, "\n")
+	if !found || strings.TrimRight(firstLine, " \t\r") != frontMatterDelimiter {
+		return nil, content, nil
+	}
+	lines := strings.SplitAfter(rest, "\n")
+	for i, line := range lines {
+		if strings.TrimRight(line, " \t\r\n") != frontMatterDelimiter {
+			continue
+		}
+		block := []byte(strings.Join(lines[:i], ""))
+		var keys map[string]interface{}
+		if err := yaml.Unmarshal(block, &keys); err != nil || len(keys) == 0 {
+			return nil, content, nil
+		}
+		var unknown []string
+		for key := range keys {
+			if !frontMatterKeys[key] {
+				unknown = append(unknown, key)
+			}
+		}
+		if len(unknown) == len(keys) {
+			return nil, content, nil
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			return nil, content, fmt.Errorf("invalid front matter: unknown keys %s", strings.Join(unknown, ", "))
+		}
+
+		fm := &FrontMatter{}
+		if err := yaml.Unmarshal(block, fm); err != nil {
+			return nil, content, fmt.Errorf("invalid front matter: %w", err)
+		}
+		if fm.Scope != "" {
+			scope, err := ParseScope(string(fm.Scope))
+			if err != nil {
+				return nil, content, fmt.Errorf("invalid front matter: %w", err)
+			}
+			fm.Scope = scope
+		}
+		for _, name := range fm.PostProcess {
+			if err := checkPostProcessor(name); err != nil {
+				return nil, content, fmt.Errorf("invalid front matter: %w", err)
+			}
+		}
+		newlines := i + 1
+		if strings.HasSuffix(line, "\n") {
+			newlines++
+		}
+		body := "{{/*" + strings.Repeat("\n", newlines) + "*/}}" + strings.Join(lines[i+1:], "")
+		return fm, body, nil
+	}
+	return nil, content, nil
+}
+
+// parseWhen parses the "when" condition of a front matter, it returns nil when there is no condition.
+func parseWhen(name string, when string, funcs tmpl.FuncMap) (*tmpl.Template, error) {
+	if strings.TrimSpace(when) == "" {
+		return nil, nil
+	}
+	if !strings.Contains(when, "{{") {
+		when = "{{" + when + "}}"
+	}
+	return tmpl.New(name + ":when").Funcs(ProtoHelpersFuncMap).Funcs(funcs).Parse(when)
+}
+
+// evalWhen evaluates the "when" condition of a front matter.
+func evalWhen(when *tmpl.Template, ast *Ast) (bool, error) {
+	if when == nil {
+		return true, nil
+	}
+	buffer := new(bytes.Buffer)
+	if err := when.Execute(buffer, ast); err != nil {
+		return false, err
+	}
+	switch strings.TrimSpace(buffer.String()) {
+	case "", "false", "0", "<no value>":
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,87 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		content string
+		fm      *FrontMatter
+		body    string
+		err     string
+	}{
+		{
+			name:    "no front matter",
+			content: "package {{.File.Package}}\n",
+			body:    "package {{.File.Package}}\n",
+		},
+		{
+			name:    "front matter",
+			content: "---\nscope: message\noutput: \"{{.Message.Name}}.go\"\npostprocess: [gofmt]\n---\nbody\n",
+			fm:      &FrontMatter{Scope: ScopeMessage, Output: "{{.Message.Name}}.go", PostProcess: []string{"gofmt"}},
+			body:    "{{/*\n\n\n\n\n*/}}body\n",
+		},
+		{
+			name:    "yaml documents",
+			content: "---\napiVersion: v1\nkind: Service\n---\napiVersion: apps/v1\nkind: Deployment\n",
+			body:    "---\napiVersion: v1\nkind: Service\n---\napiVersion: apps/v1\nkind: Deployment\n",
+		},
+		{
+			name:    "yaml document with templates",
+			content: "---\nmetadata:\n  name: {{.File.Package}}\n---\n",
+			body:    "---\nmetadata:\n  name: {{.File.Package}}\n---\n",
+		},
+		{
+			name:    "single yaml document",
+			content: "---\napiVersion: v1\n",
+			body:    "---\napiVersion: v1\n",
+		},
+		{
+			name:    "unknown keys",
+			content: "---\nscope: message\nscpoe: file\n---\n",
+			err:     "invalid front matter: unknown keys scpoe",
+		},
+		{
+			name:    "invalid scope",
+			content: "---\nscope: nope\n---\n",
+			err:     "invalid front matter",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fm, body, err := splitFrontMatter(test.content)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("splitFrontMatter() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("splitFrontMatter() error = %v", err)
+			}
+			if !reflect.DeepEqual(fm, test.fm) {
+				t.Errorf("splitFrontMatter() front matter = %+v, want %+v", fm, test.fm)
+			}
+			if body != test.body {
+				t.Errorf("splitFrontMatter() body = %q, want %q", body, test.body)
+			}
+		})
+	}
+}
+
+func TestFrontMatterLineNumbers(t *testing.T) {
+	dir := t.TempDir()
+	content := "---\nscope: file\nvars:\n  a: b\n---\nline 6\n{{.Nope | nope}}\n"
+	if err := os.WriteFile(filepath.Join(dir, "a.txt.tmpl"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadTemplateSet(dir, Filter{}, nil, false)
+	if err == nil || !strings.Contains(err.Error(), "a.txt.tmpl:7:") {
+		t.Fatalf("LoadTemplateSet() error = %v, want an error at a.txt.tmpl:7", err)
+	}
+}
@@ -0,0 +1,109 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	tmpl "text/template"
+)
+
+// Header is prepended to the outputs, as a comment whose syntax depends on their extension.
+// It starts with a "Code generated ... DO NOT EDIT." marker naming the proto file and the template,
+// followed by the rendering of its template, e.g. a license banner.
+// Insertions have no header, unless Insertions is set: they then only get the marker.
+type Header struct {
+	Insertions bool
+	template   *tmpl.Template
+}
+
+// ParseHeader parses the template of a header, rendered with the Ast of each output.
+// The helpers given by funcs, if any, override the ones of ProtoHelpersFuncMap.
+func ParseHeader(name string, text string, funcs tmpl.FuncMap) (*Header, error) {
+	t, err := tmpl.New(name).Funcs(ProtoHelpersFuncMap).Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Header{template: t}, nil
+}
+
+// commentSyntax is the syntax of the comments of a file format, end being empty for line comments.
+type commentSyntax struct {
+	start string
+	end   string
+}
+
+// commentSyntaxes are the comment syntaxes by extension, the outputs of the other extensions have no header.
+var commentSyntaxes = map[string]commentSyntax{
+	"go": {start: "//"}, "js": {start: "//"}, "jsx": {start: "//"}, "ts": {start: "//"}, "tsx": {start: "//"},
+	"java": {start: "//"}, "kt": {start: "//"}, "scala": {start: "//"}, "swift": {start: "//"}, "dart": {start: "//"},
+	"c": {start: "//"}, "h": {start: "//"}, "cc": {start: "//"}, "cpp": {start: "//"}, "hpp": {start: "//"},
+	"cs": {start: "//"}, "rs": {start: "//"}, "proto": {start: "//"},
+	"yaml": {start: "#"}, "yml": {start: "#"}, "toml": {start: "#"}, "sh": {start: "#"}, "bash": {start: "#"},
+	"py": {start: "#"}, "rb": {start: "#"}, "tf": {start: "#"}, "mk": {start: "#"},
+	"sql": {start: "--"}, "lua": {start: "--"},
+	"css": {start: "/*", end: "*/"},
+	"xml": {start: "<!--", end: "-->"}, "html": {start: "<!--", end: "-->"}, "htm": {start: "<!--", end: "-->"},
+	"svg": {start: "<!--", end: "-->"}, "md": {start: "<!--", end: "-->"}, "vue": {start: "<!--", end: "-->"},
+}
+
+// render renders the header of an output as a comment, it is empty when the extension has no known comment syntax.
+func (h *Header) render(name string, ast *Ast, protoFile string, templatePath string, insertion bool) (string, error) {
+	syntax, ok := commentSyntaxes[strings.TrimPrefix(path.Ext(name), ".")]
+	if !ok || (insertion && !h.Insertions) {
+		return "", nil
+	}
+	lines := []string{fmt.Sprintf("Code generated by protoc-gen-gotemplate from %s with %s. DO NOT EDIT.", protoFile, templatePath)}
+	if !insertion {
+		buffer := new(bytes.Buffer)
+		if err := h.template.Execute(buffer, ast); err != nil {
+			return "", err
+		}
+		if banner := strings.TrimRight(buffer.String(), "\n"); banner != "" {
+			lines = append(lines, "")
+			lines = append(lines, strings.Split(banner, "\n")...)
+		}
+	}
+	return syntax.comment(lines), nil
+}
+
+// PrependHeader prepends the header rendered for an output, see Output.Header, to the content of a file.
+// Shebangs and XML declarations stay on the first line.
+func PrependHeader(content string, header string, insertion bool) string {
+	if header == "" {
+		return content
+	}
+	if insertion {
+		return header + content
+	}
+	if strings.HasPrefix(content, "#!") || strings.HasPrefix(content, "<?xml") {
+		if first, rest, found := strings.Cut(content, "\n"); found {
+			return first + "\n" + header + "\n" + rest
+		}
+	}
+	return header + "\n" + content
+}
+
+// comment comments out lines.
+func (s commentSyntax) comment(lines []string) string {
+	var b strings.Builder
+	if s.end != "" {
+		if len(lines) == 1 {
+			return s.start + " " + lines[0] + " " + s.end + "\n"
+		}
+		b.WriteString(s.start + "\n")
+		for _, line := range lines {
+			b.WriteString(line + "\n")
+		}
+		b.WriteString(s.end + "\n")
+		return b.String()
+	}
+	for _, line := range lines {
+		if line == "" {
+			b.WriteString(s.start + "\n")
+			continue
+		}
+		b.WriteString(s.start + " " + line + "\n")
+	}
+	return b.String()
+}
@@ -200,6 +200,9 @@ var ProtoHelpersFuncMap = tmpl.FuncMap{
 		}
 		return a / b
 	},
+	"skip":     skip,
+	"required": required,
+	"fail":     fail,
 
 	"snakeCase":                    xstrings.ToSnakeCase,
 	"getProtoFile":                 getProtoFile,
@@ -251,7 +254,13 @@ var ProtoHelpersFuncMap = tmpl.FuncMap{
 	"rustTypeWithPackage":          rustTypeWithPackage,
 }
 
-var pathMap map[interface{}]*descriptor.SourceCodeInfo_Location
+// pathMap maps the elements of every loaded file to their source location, for the comment helpers.
+// It is shared by the templates rendered concurrently, filePathMaps caches the locations per file.
+var (
+	pathMap      = make(map[interface{}]*descriptor.SourceCodeInfo_Location)
+	filePathMaps = make(map[*descriptor.FileDescriptorProto]map[interface{}]*descriptor.SourceCodeInfo_Location)
+	pathMapMu    sync.RWMutex
+)
 
 var store = newStore()
 
@@ -293,17 +302,49 @@ func getStore(key string) interface{} {
 	return store.getData(key)
 }
 
+// skip aborts the current template execution, no file is generated for it.
+func skip(reason ...string) (string, error) {
+	if len(reason) == 0 {
+		return "", errSkipTemplate
+	}
+	return "", fmt.Errorf("%w: %s", errSkipTemplate, strings.Join(reason, " "))
+}
+
 func SetRegistry(reg *Registry) {
 	registry = reg
 }
-func LoadComments(file *descriptor.FileDescriptorProto) {
-	pathMap = make(map[interface{}]*descriptor.SourceCodeInfo_Location)
-	addToPathMap(file.GetSourceCodeInfo(), file, []int32{})
+// LoadComments registers the source locations of the files, so that the comment helpers can look them up.
+func LoadComments(files ...*descriptor.FileDescriptorProto) {
+	loadComments(files...)
+}
+
+// loadComments registers the source locations of the files and returns them.
+// The locations of a file are only computed once.
+func loadComments(files ...*descriptor.FileDescriptorProto) map[interface{}]*descriptor.SourceCodeInfo_Location {
+	pathMapMu.Lock()
+	defer pathMapMu.Unlock()
+
+	locations := make(map[interface{}]*descriptor.SourceCodeInfo_Location)
+	for _, file := range files {
+		fileMap, ok := filePathMaps[file]
+		if !ok {
+			fileMap = make(map[interface{}]*descriptor.SourceCodeInfo_Location)
+			addToPathMap(fileMap, file.GetSourceCodeInfo(), file, []int32{})
+			filePathMaps[file] = fileMap
+			for i, loc := range fileMap {
+				pathMap[i] = loc
+			}
+		}
+		for i, loc := range fileMap {
+			locations[i] = loc
+		}
+	}
+	return locations
 }
 
 // addToPathMap traverses through the AST adding SourceCodeInfo_Location entries to the pathMap.
 // Since the AST is a tree, the recursion finishes once it has gone through all the nodes.
-func addToPathMap(info *descriptor.SourceCodeInfo, i interface{}, path []int32) {
+func addToPathMap(pathMap map[interface{}]*descriptor.SourceCodeInfo_Location, info *descriptor.SourceCodeInfo, i interface{}, path []int32) {
 	loc := findLoc(info, path)
 	if loc != nil {
 		pathMap[i] = loc
@@ -311,31 +352,31 @@ func addToPathMap(info *descriptor.SourceCodeInfo, i interface{}, path []int32)
 	switch d := i.(type) {
 	case *descriptor.FileDescriptorProto:
 		for index, descriptorProto := range d.MessageType {
-			addToPathMap(info, descriptorProto, newPath(path, 4, index))
+			addToPathMap(pathMap, info, descriptorProto, newPath(path, 4, index))
 		}
 		for index, descriptorProto := range d.EnumType {
-			addToPathMap(info, descriptorProto, newPath(path, 5, index))
+			addToPathMap(pathMap, info, descriptorProto, newPath(path, 5, index))
 		}
 		for index, descriptorProto := range d.Service {
-			addToPathMap(info, descriptorProto, newPath(path, 6, index))
+			addToPathMap(pathMap, info, descriptorProto, newPath(path, 6, index))
 		}
 	case *descriptor.DescriptorProto:
 		for index, descriptorProto := range d.Field {
-			addToPathMap(info, descriptorProto, newPath(path, 2, index))
+			addToPathMap(pathMap, info, descriptorProto, newPath(path, 2, index))
 		}
 		for index, descriptorProto := range d.NestedType {
-			addToPathMap(info, descriptorProto, newPath(path, 3, index))
+			addToPathMap(pathMap, info, descriptorProto, newPath(path, 3, index))
 		}
 		for index, descriptorProto := range d.EnumType {
-			addToPathMap(info, descriptorProto, newPath(path, 4, index))
+			addToPathMap(pathMap, info, descriptorProto, newPath(path, 4, index))
 		}
 	case *descriptor.EnumDescriptorProto:
 		for index, descriptorProto := range d.Value {
-			addToPathMap(info, descriptorProto, newPath(path, 2, index))
+			addToPathMap(pathMap, info, descriptorProto, newPath(path, 2, index))
 		}
 	case *descriptor.ServiceDescriptorProto:
 		for index, descriptorProto := range d.Method {
-			addToPathMap(info, descriptorProto, newPath(path, 2, index))
+			addToPathMap(pathMap, info, descriptorProto, newPath(path, 2, index))
 		}
 	}
 }
@@ -389,7 +430,7 @@ func parseExpression(re *regexp.Regexp, str string) []map[string]string {
 
 var directiveRe = regexp.MustCompile(`(?s)@@(?P<directive>[^(]*)(?:\((?P<params>[^)]+(?:,\s)?)\)\s*\x60{0,3}\s*(?P<value>[^\x60@]*)?\s*\x60{0,3})?`)
 
-func parseDirectives(dMap *map[interface{}][]CommentDirective) {
+func parseDirectives(dMap *map[interface{}][]CommentDirective, pathMap map[interface{}]*descriptor.SourceCodeInfo_Location) {
 	directivesMap := *dMap
 	for i, loc := range pathMap {
 		leading := strings.Trim(loc.GetLeadingComments(), " \t\r\n")
@@ -443,16 +484,22 @@ func parseDirectives(dMap *map[interface{}][]CommentDirective) {
 	}
 }
 
+func lookupLoc(i interface{}) *descriptor.SourceCodeInfo_Location {
+	pathMapMu.RLock()
+	defer pathMapMu.RUnlock()
+	return pathMap[i]
+}
+
 func leadingComment(i interface{}) string {
-	loc := pathMap[i]
+	loc := lookupLoc(i)
 	return loc.GetLeadingComments()
 }
 func trailingComment(i interface{}) string {
-	loc := pathMap[i]
+	loc := lookupLoc(i)
 	return loc.GetTrailingComments()
 }
 func leadingDetachedComments(i interface{}) []string {
-	loc := pathMap[i]
+	loc := lookupLoc(i)
 	return loc.GetLeadingDetachedComments()
 }
 
@@ -1338,7 +1385,7 @@ func httpPath(m *descriptor.MethodDescriptorProto) string {
 		return fmt.Sprintf("extension is %T; want an HttpRule", ext)
 	}
 
-	switch t := opts.Pattern.(type) {
+	switch t := opts.GetPattern().(type) {
 	default:
 		return ""
 	case *options.HttpRule_Get:
@@ -1403,7 +1450,7 @@ func httpVerb(m *descriptor.MethodDescriptorProto) string {
 		return fmt.Sprintf("extension is %T; want an HttpRule", ext)
 	}
 
-	switch t := opts.Pattern.(type) {
+	switch t := opts.GetPattern().(type) {
 	default:
 		return ""
 	case *options.HttpRule_Get:
@@ -1431,7 +1478,7 @@ func httpBody(m *descriptor.MethodDescriptorProto) string {
 	if !ok {
 		return fmt.Sprintf("extension is %T; want an HttpRule", ext)
 	}
-	return opts.Body
+	return opts.GetBody()
 }
 
 func urlHasVarsFromMessage(path string, d *Message) bool {
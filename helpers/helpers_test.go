@@ -0,0 +1,39 @@
+package helpers
+
+import (
+	"testing"
+
+	options "google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	descriptor "google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestHTTPHelpers(t *testing.T) {
+	withRule := &descriptor.MethodDescriptorProto{Name: proto.String("Get"), Options: &descriptor.MethodOptions{}}
+	proto.SetExtension(withRule.Options, options.E_Http, &options.HttpRule{
+		Pattern: &options.HttpRule_Post{Post: "/v1/things"},
+		Body:    "*",
+	})
+
+	for _, test := range []struct {
+		name             string
+		method           *descriptor.MethodDescriptorProto
+		verb, path, body string
+	}{
+		{"no options", &descriptor.MethodDescriptorProto{Name: proto.String("Get")}, "", "", ""},
+		{"no http rule", &descriptor.MethodDescriptorProto{Name: proto.String("Get"), Options: &descriptor.MethodOptions{}}, "", "", ""},
+		{"http rule", withRule, "POST", "/v1/things", "*"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := httpVerb(test.method); got != test.verb {
+				t.Errorf("httpVerb() = %q, want %q", got, test.verb)
+			}
+			if got := httpPath(test.method); got != test.path {
+				t.Errorf("httpPath() = %q, want %q", got, test.path)
+			}
+			if got := httpBody(test.method); got != test.body {
+				t.Errorf("httpBody() = %q, want %q", got, test.body)
+			}
+		})
+	}
+}
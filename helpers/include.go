@@ -0,0 +1,90 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	tmpl "text/template"
+)
+
+// maxIncludeDepth bounds the recursion of include and tpl.
+const maxIncludeDepth = 1000
+
+// includer provides the helpers rendering templates of the set being executed:
+//
+//	{{include "name" . | indent 4}}
+//	{{tpl (stringMessageExtension 50000 .Message) .}}
+type includer struct {
+	template *tmpl.Template
+	// emitter collects the file blocks of the execution, including the ones of the rendered templates.
+	emitter *emitter
+	depth   int
+}
+
+func (in *includer) funcMap() tmpl.FuncMap {
+	return tmpl.FuncMap{
+		"include": in.include,
+		"tpl":     in.tpl,
+	}
+}
+
+// include renders the named template into a string.
+func (in *includer) include(name string, data interface{}) (string, error) {
+	if in.depth >= maxIncludeDepth {
+		return "", fmt.Errorf("include %q: rendering template has a nested reference name too deep", name)
+	}
+	in.depth++
+	defer func() { in.depth-- }()
+
+	buffer := new(bytes.Buffer)
+	err := in.emitter.within(buffer, func() error {
+		return in.template.ExecuteTemplate(buffer, name, data)
+	})
+	if err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// tpl renders a string as a template, it can use the templates defined in the set being executed.
+func (in *includer) tpl(text string, data interface{}) (string, error) {
+	if in.depth >= maxIncludeDepth {
+		return "", fmt.Errorf("tpl: rendering template has a nested reference name too deep")
+	}
+	in.depth++
+	defer func() { in.depth-- }()
+
+	t, err := in.template.Clone()
+	if err != nil {
+		return "", err
+	}
+	t, err = t.New(in.template.Name() + ":tpl").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	buffer := new(bytes.Buffer)
+	err = in.emitter.within(buffer, func() error {
+		return t.Execute(buffer, data)
+	})
+	if err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// required fails the generation with the given message when the value is nil or an empty string.
+func required(msg string, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, fmt.Errorf("%s", msg)
+	case string:
+		if v == "" {
+			return nil, fmt.Errorf("%s", msg)
+		}
+	}
+	return value, nil
+}
+
+// fail fails the generation with the given message.
+func fail(msg string) (string, error) {
+	return "", fmt.Errorf("%s", msg)
+}
@@ -0,0 +1,84 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	descriptor "google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestIncludeFileBlocks(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		templates map[string]string
+		outputs   map[string]string
+		err       string
+	}{
+		{
+			name: "include",
+			templates: map[string]string{
+				"_inc.tmpl":     `{{define "inc"}}[{{file "inc.out" .}}inside{{endfile}}]{{end}}`,
+				"main.txt.tmpl": `before {{include "inc" .}} after`,
+			},
+			outputs: map[string]string{"inc.out": "inside", "main.txt": "before [] after"},
+		},
+		{
+			name: "tpl",
+			templates: map[string]string{
+				"main.txt.tmpl": `before {{tpl "[{{file \"tpl.out\" .}}inside{{endfile}}]" .}} after`,
+			},
+			outputs: map[string]string{"tpl.out": "inside", "main.txt": "before [] after"},
+		},
+		{
+			name: "file block around include",
+			templates: map[string]string{
+				"_inc.tmpl":     `{{define "inc"}}included{{end}}`,
+				"main.txt.tmpl": `before {{file "out.txt" .}}{{include "inc" .}}{{endfile}} after`,
+			},
+			outputs: map[string]string{"out.txt": "included", "main.txt": "before  after"},
+		},
+		{
+			name: "unended file block in include",
+			templates: map[string]string{
+				"_inc.tmpl":     `{{define "inc"}}{{file "inc.out" .}}inside{{end}}`,
+				"main.txt.tmpl": `{{include "inc" .}}{{endfile}}`,
+			},
+			err: `file "inc.out": missing endfile`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range test.templates {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			file := &descriptor.FileDescriptorProto{Name: proto.String("a.proto"), Package: proto.String("a")}
+			files, err := NewGenericTemplateBasedEncoder(dir, file, false, ".", 0).Render()
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("Render() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			outputs := make(map[string]string, len(files))
+			for _, f := range files {
+				outputs[f.GetName()] = f.GetContent()
+			}
+			if len(outputs) != len(test.outputs) {
+				t.Errorf("Render() outputs = %q, want %q", outputs, test.outputs)
+			}
+			for name, want := range test.outputs {
+				if got := outputs[name]; got != want {
+					t.Errorf("Render() output %s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
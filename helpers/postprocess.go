@@ -0,0 +1,111 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PostProcessor transforms the content of an output once rendered.
+type PostProcessor func(content []byte) ([]byte, error)
+
+// PostProcessors are the available post-processors, by name:
+//
+//	gofmt       formats Go code with go/format
+//	json        pretty prints JSON, indented with 2 spaces
+//	blank_lines collapses runs of blank lines into a single one
+//	newlines    converts the line endings to "\n" and ends the content with a single newline
+var PostProcessors = map[string]PostProcessor{
+	"gofmt":       format.Source,
+	"json":        prettyJSON,
+	"blank_lines": collapseBlankLines,
+	"newlines":    normalizeNewlines,
+}
+
+// DefaultPostProcessing are the post-processors applied by extension when post-processing is enabled.
+var DefaultPostProcessing = PostProcessing{
+	"go":   {"gofmt"},
+	"json": {"json"},
+}
+
+// PostProcessing lists the post-processors applied to the outputs, by extension without the leading dot.
+// The post-processors of the "*" extension apply to the outputs whose extension is not listed.
+type PostProcessing map[string][]string
+
+// ParsePostProcessors parses a list of post-processors separated by "+", e.g. "gofmt+newlines".
+func ParsePostProcessors(list string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(list, "+") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if err := checkPostProcessor(name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func checkPostProcessor(name string) error {
+	if _, ok := PostProcessors[name]; ok {
+		return nil
+	}
+	names := make([]string, 0, len(PostProcessors))
+	for name := range PostProcessors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("unknown post-processor %q, expected one of: %s", name, strings.Join(names, ", "))
+}
+
+// of returns the post-processors applied to an output.
+func (p PostProcessing) of(name string) []string {
+	if names, ok := p[strings.TrimPrefix(path.Ext(name), ".")]; ok {
+		return names
+	}
+	return p["*"]
+}
+
+// postProcess applies the post-processors to the content of an output.
+func postProcess(names []string, content string) (string, error) {
+	data := []byte(content)
+	for _, name := range names {
+		var err error
+		if data, err = PostProcessors[name](data); err != nil {
+			return "", fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return string(data), nil
+}
+
+func prettyJSON(content []byte) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	if err := json.Indent(buffer, bytes.TrimSpace(content), "", "  "); err != nil {
+		return nil, err
+	}
+	buffer.WriteByte('\n')
+	return buffer.Bytes(), nil
+}
+
+var blankLinesRe = regexp.MustCompile(`\n([ \t]*\r?\n){2,}`)
+
+func collapseBlankLines(content []byte) ([]byte, error) {
+	return blankLinesRe.ReplaceAll(content, []byte("\n\n")), nil
+}
+
+func normalizeNewlines(content []byte) ([]byte, error) {
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	content = bytes.ReplaceAll(content, []byte("\r"), []byte("\n"))
+	content = bytes.TrimRight(content, "\n")
+	if len(content) == 0 {
+		return content, nil
+	}
+	return append(content, '\n'), nil
+}
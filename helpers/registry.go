@@ -347,6 +347,5 @@ func (r *Registry) packageIdentityName(f *descriptor.FileDescriptorProto) string
 
 func (r *Registry) registerComments(f *descriptor.FileDescriptorProto) {
 	r.directivesMap = make(map[interface{}][]CommentDirective)
-	LoadComments(f)
-	parseDirectives(&r.directivesMap)
+	parseDirectives(&r.directivesMap, loadComments(f))
 }
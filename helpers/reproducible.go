@@ -0,0 +1,107 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	tmpl "text/template"
+	"time"
+)
+
+// nondeterministicFuncs are the helpers whose result changes on every call, or between machines.
+var nondeterministicFuncs = []string{
+	"ago",
+	"encryptAES",
+	"env",
+	"expandenv",
+	"genCA",
+	"genPrivateKey",
+	"genSelfSignedCert",
+	"genSignedCert",
+	"getHostByName",
+	"randAlpha",
+	"randAlphaNum",
+	"randAscii",
+	"randNumeric",
+	"shuffle",
+	"uuidv4",
+}
+
+// SourceDate returns the date given by the SOURCE_DATE_EPOCH environment variable,
+// see https://reproducible-builds.org/specs/source-date-epoch/, or the Unix epoch when it is not set.
+func SourceDate() (time.Time, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Unix(0, 0).UTC(), nil
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %w", epoch, err)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// ReproducibleFuncMap returns the helpers overriding the nondeterministic ones for reproducible builds:
+// now returns date, the date helpers use it instead of the current time and UTC instead of the local zone,
+// the others fail the generation.
+func ReproducibleFuncMap(date time.Time) tmpl.FuncMap {
+	dateInZone := func(format string, value interface{}, zone string) string {
+		return pinDate(value, date).In(location(zone)).Format(format)
+	}
+	funcs := tmpl.FuncMap{
+		"now": func() time.Time { return date },
+		"date": func(format string, value interface{}) string {
+			return dateInZone(format, value, "UTC")
+		},
+		"htmlDate": func(value interface{}) string {
+			return dateInZone("2006-01-02", value, "UTC")
+		},
+		"htmlDateInZone": func(value interface{}, zone string) string {
+			return dateInZone("2006-01-02", value, zone)
+		},
+		"dateInZone":   dateInZone,
+		"date_in_zone": dateInZone,
+		"toDate": func(format string, value string) time.Time {
+			t, _ := time.ParseInLocation(format, value, time.UTC)
+			return t
+		},
+	}
+	for _, name := range nondeterministicFuncs {
+		name := name
+		funcs[name] = func(...interface{}) (string, error) {
+			return "", fmt.Errorf("%s is not available in reproducible mode", name)
+		}
+	}
+	return funcs
+}
+
+// pinDate returns the time given to a date helper, which is the current time in sprig
+// when the value is neither a time nor a Unix time.
+func pinDate(value interface{}, date time.Time) time.Time {
+	switch v := value.(type) {
+	case time.Time:
+		return v
+	case *time.Time:
+		return *v
+	case int64:
+		return time.Unix(v, 0)
+	case int:
+		return time.Unix(int64(v), 0)
+	case int32:
+		return time.Unix(int64(v), 0)
+	}
+	return date
+}
+
+// location returns the time zone of a date helper, UTC for the local zone of the machine
+// or an unknown zone.
+func location(zone string) *time.Location {
+	if zone == "Local" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
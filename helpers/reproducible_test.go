@@ -0,0 +1,56 @@
+package helpers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	tmpl "text/template"
+	"time"
+)
+
+func TestReproducibleFuncMap(t *testing.T) {
+	// the outputs must not depend on the time zone of the machine
+	local := time.Local
+	time.Local = time.FixedZone("TEST", 5*3600)
+	defer func() { time.Local = local }()
+
+	date := time.Date(2020, 2, 3, 4, 5, 6, 0, time.UTC)
+	funcs := ReproducibleFuncMap(date)
+	for _, test := range []struct {
+		text, want, err string
+	}{
+		{text: `{{now | date "2006-01-02 15:04"}}`, want: "2020-02-03 04:05"},
+		{text: `{{date "2006-01-02 15:04" "not a date"}}`, want: "2020-02-03 04:05"},
+		{text: `{{date "2006-01-02 15:04" 0}}`, want: "1970-01-01 00:00"},
+		{text: `{{htmlDate "not a date"}}`, want: "2020-02-03"},
+		{text: `{{dateInZone "15:04" "not a date" "UTC"}}`, want: "04:05"},
+		{text: `{{date_in_zone "15:04" "not a date" "Local"}}`, want: "04:05"},
+		{text: `{{htmlDateInZone "not a date" "UTC"}}`, want: "2020-02-03"},
+		{text: `{{toDate "2006-01-02" "2021-05-06" | unixEpoch}}`, want: "1620259200"},
+		{text: `{{now | dateModify "1h" | date "15:04"}}`, want: "05:05"},
+		{text: `{{env "HOME"}}`, err: "env is not available in reproducible mode"},
+		{text: `{{expandenv "$HOME"}}`, err: "expandenv is not available in reproducible mode"},
+		{text: `{{randNumeric 3}}`, err: "randNumeric is not available in reproducible mode"},
+	} {
+		t.Run(test.text, func(t *testing.T) {
+			parsed, err := tmpl.New("").Funcs(ProtoHelpersFuncMap).Funcs(funcs).Parse(test.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buffer := new(bytes.Buffer)
+			err = parsed.Execute(buffer, nil)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("Execute() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buffer.String(); got != test.want {
+				t.Errorf("Execute() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+
+	descriptor "google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Scope describes the protobuf element a template is rendered for.
+type Scope string
+
+const (
+	// ScopeService renders templates once per service (default).
+	ScopeService Scope = "service"
+	// ScopeFile renders templates once per proto file.
+	ScopeFile Scope = "file"
+	// ScopeMessage renders templates once per message, nested messages included.
+	ScopeMessage Scope = "message"
+	// ScopeEnum renders templates once per enum, nested enums included.
+	ScopeEnum Scope = "enum"
+	// ScopeMethod renders templates once per RPC method.
+	ScopeMethod Scope = "method"
+	// ScopePackage renders templates once per proto package.
+	ScopePackage Scope = "package"
+	// ScopeRequest renders templates once for the whole CodeGeneratorRequest.
+	ScopeRequest Scope = "request"
+)
+
+// Scopes lists every supported scope.
+var Scopes = []Scope{ScopeService, ScopeFile, ScopeMessage, ScopeEnum, ScopeMethod, ScopePackage, ScopeRequest}
+
+// ParseScope converts a plugin parameter value to a Scope.
+func ParseScope(s string) (Scope, error) {
+	for _, scope := range Scopes {
+		if strings.EqualFold(s, string(scope)) {
+			return scope, nil
+		}
+	}
+	names := make([]string, len(Scopes))
+	for i, scope := range Scopes {
+		names[i] = string(scope)
+	}
+	return "", fmt.Errorf("invalid scope %q, expected one of: %s", s, strings.Join(names, ", "))
+}
+
+// AllMessages returns the messages of a file, depth-first, nested messages included.
+func AllMessages(file *descriptor.FileDescriptorProto) []*descriptor.DescriptorProto {
+	var messages []*descriptor.DescriptorProto
+	var walk func([]*descriptor.DescriptorProto)
+	walk = func(msgs []*descriptor.DescriptorProto) {
+		for _, msg := range msgs {
+			messages = append(messages, msg)
+			walk(msg.GetNestedType())
+		}
+	}
+	walk(file.GetMessageType())
+	return messages
+}
+
+// AllEnums returns the enums of a file, top-level enums first, then the ones nested in messages.
+func AllEnums(file *descriptor.FileDescriptorProto) []*descriptor.EnumDescriptorProto {
+	enums := append([]*descriptor.EnumDescriptorProto{}, file.GetEnumType()...)
+	for _, msg := range AllMessages(file) {
+		enums = append(enums, msg.GetEnumType()...)
+	}
+	return enums
+}
+
+// PackageFiles groups files by proto package, keeping the order in which packages are first seen.
+func PackageFiles(files []*descriptor.FileDescriptorProto) (packages []string, groups map[string][]*descriptor.FileDescriptorProto) {
+	groups = make(map[string][]*descriptor.FileDescriptorProto)
+	for _, file := range files {
+		pkg := file.GetPackage()
+		if _, ok := groups[pkg]; !ok {
+			packages = append(packages, pkg)
+		}
+		groups[pkg] = append(groups[pkg], file)
+	}
+	return packages, groups
+}
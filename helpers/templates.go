@@ -0,0 +1,239 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	tmpl "text/template"
+)
+
+// partialsDir is the directory of template_dir holding the partials.
+const partialsDir = "partials"
+
+type template struct {
+	fileName       string
+	content        string
+	insertionPoint string
+	frontMatter    *FrontMatter
+	partial        bool
+
+	// parsed is the template, parsed along with the partials; it is cloned for the executions.
+	parsed *tmpl.Template
+	// executions holds the clones of parsed which are not in use.
+	executions sync.Pool
+	// filename is the parsed template of the output filename.
+	filename *tmpl.Template
+	// when is the parsed front matter condition, if any.
+	when *tmpl.Template
+	// funcs override the helpers, paths holds the output paths of the emitter once parsed.
+	funcs tmpl.FuncMap
+	paths sync.Map
+}
+
+// scope returns the scope declared by the template front matter, if any.
+func (t *template) scope() Scope {
+	if t.frontMatter == nil {
+		return ""
+	}
+	return t.frontMatter.Scope
+}
+
+// execution is a clone of a parsed template, bound to its own execution helpers.
+type execution struct {
+	template *tmpl.Template
+	emitter  *emitter
+	includer *includer
+}
+
+// acquire returns an execution of the template writing to buffer.
+// Cloning a template copies its whole FuncMap, so the clones are reused rather than created for every execution.
+func (t *template) acquire(buffer *bytes.Buffer) (*execution, error) {
+	if ex, ok := t.executions.Get().(*execution); ok {
+		ex.emitter.reset(buffer)
+		return ex, nil
+	}
+	clone, err := t.parsed.Clone()
+	if err != nil {
+		return nil, err
+	}
+	em := newEmitter(buffer, t.funcs, &t.paths)
+	ex := &execution{
+		template: clone,
+		emitter:  em,
+		includer: &includer{template: clone, emitter: em},
+	}
+	clone.Funcs(ex.emitter.funcMap()).Funcs(ex.includer.funcMap())
+	return ex, nil
+}
+
+// release makes an execution available for reuse.
+func (t *template) release(ex *execution) {
+	ex.emitter.reset(nil)
+	t.executions.Put(ex)
+}
+
+// isPartial reports whether a template file, relative to template_dir, is a partial.
+// Partials are not rendered on their own, they are parsed along with every template so that
+// their defined templates can be used with {{template "name" .}}.
+func isPartial(rel string) bool {
+	return strings.HasPrefix(filepath.Base(rel), "_") ||
+		strings.SplitN(filepath.ToSlash(rel), "/", 2)[0] == partialsDir
+}
+
+// TemplateSet is the set of templates of a template directory.
+// The templates are discovered, read and parsed once per run, then cloned for the executions,
+// so that a single set can be shared by every encoder.
+type TemplateSet struct {
+	dir       string
+	templates []*template
+	// funcs override the helpers.
+	funcs tmpl.FuncMap
+	// base holds the helpers and the partials, every template is parsed in a clone of it.
+	base *tmpl.Template
+}
+
+// LoadTemplateSet walks templateDir, reads and parses every template found.
+// The templates are selected by filter, with their path relative to templateDir, partials excepted;
+// the files listed by the ignore file of templateDir are skipped, partials included.
+// The helpers given by funcs, if any, override the ones of ProtoHelpersFuncMap.
+func LoadTemplateSet(templateDir string, filter Filter, funcs tmpl.FuncMap, debug bool) (*TemplateSet, error) {
+	files, err := loadTemplateFiles(templateDir, filter, debug)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &TemplateSet{
+		dir:   templateDir,
+		funcs: funcs,
+		// the execution helpers are registered for parsing, they are bound to the execution when cloned
+		base: tmpl.New("").Funcs(ProtoHelpersFuncMap).Funcs(funcs).Funcs(newEmitter(nil, nil, nil).funcMap()).Funcs((&includer{}).funcMap()),
+	}
+	for _, t := range files {
+		if !t.partial {
+			continue
+		}
+		if _, err := s.base.New(t.fileName).Parse(t.content); err != nil {
+			return nil, err
+		}
+	}
+	for _, t := range files {
+		if t.partial {
+			continue
+		}
+		if err := s.parse(t); err != nil {
+			return nil, err
+		}
+		s.templates = append(s.templates, t)
+	}
+	return s, nil
+}
+
+// Dir returns the template directory of the set.
+func (s *TemplateSet) Dir() string {
+	return s.dir
+}
+
+// Scopes returns the scopes declared by the front matter of the templates.
+func (s *TemplateSet) Scopes() []Scope {
+	declared := make(map[Scope]bool)
+	for _, t := range s.templates {
+		declared[t.scope()] = true
+	}
+	scopes := make([]Scope, 0, len(declared))
+	for _, scope := range Scopes {
+		if declared[scope] {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// parse parses the content, the output filename and the condition of a template.
+func (s *TemplateSet) parse(t *template) error {
+	base, err := s.base.Clone()
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(filepath.Join(s.dir, t.fileName))
+	if t.parsed, err = base.New(name).Parse(t.content); err != nil {
+		return err
+	}
+	t.funcs = s.funcs
+
+	filename := t.fileName
+	if t.frontMatter != nil && t.frontMatter.Output != "" {
+		filename = t.frontMatter.Output
+	} else if unescaped, err := url.QueryUnescape(filename); err != nil {
+		log.Printf("failed to unescape filepath %q: %v", filename, err)
+	} else {
+		filename = unescaped
+	}
+	if t.filename, err = tmpl.New("").Funcs(ProtoHelpersFuncMap).Funcs(s.funcs).Parse(filename); err != nil {
+		return err
+	}
+
+	if t.frontMatter != nil {
+		if t.when, err = parseWhen(name, t.frontMatter.When, s.funcs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTemplateFiles walks templateDir and reads every template found, front matter included, partials included.
+func loadTemplateFiles(templateDir string, filter Filter, debug bool) ([]*template, error) {
+	templates := make([]*template, 0)
+	rules, err := loadIgnoreFile(templateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	err = filepath.Walk(templateDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(templateDir, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if rel != "." && ignored(rules, rel, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".tmpl" || ignored(rules, rel, false) {
+			return nil
+		}
+		partial := isPartial(rel)
+		if !partial && !filter.Match(filepath.ToSlash(rel)) {
+			return nil
+		}
+		if debug {
+			log.Printf("new template: %q", rel)
+		}
+		data, err := ioutil.ReadFile(path) // #nosec
+		if err != nil {
+			return err
+		}
+		fm, content, err := splitFrontMatter(string(data))
+		if err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+
+		templates = append(templates, &template{
+			fileName:    rel,
+			content:     content,
+			frontMatter: fm,
+			partial:     partial,
+		})
+		return nil
+	})
+	return templates, err
+}
@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	descriptor "google.golang.org/protobuf/types/descriptorpb"
+)
+
+// benchmarkTemplate is a template using partials and helpers, as the templates of the examples do.
+const benchmarkTemplate = `{{template "header" .}}
+package {{.File.Package | replace "." "_"}}
+{{range .File.MessageType}}
+type {{.Name | camelCase}} struct {
+{{- range .Field}}
+	{{.Name | camelCase}} {{.Type | toString | lower}}
+{{- end}}
+}
+{{end}}
+`
+
+// writeBenchmarkTemplates writes n templates and a partial to a temporary directory.
+func writeBenchmarkTemplates(b *testing.B, n int) string {
+	b.Helper()
+	dir := b.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "_header.tmpl"), []byte(`{{define "header"}}// {{.File.Name}}{{end}}`), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("t%d.go.tmpl", i))
+		if err := os.WriteFile(name, []byte(benchmarkTemplate), 0o644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// benchmarkFiles returns n proto files of a few messages.
+func benchmarkFiles(n int) []*descriptor.FileDescriptorProto {
+	files := make([]*descriptor.FileDescriptorProto, n)
+	for i := range files {
+		file := &descriptor.FileDescriptorProto{
+			Name:    proto.String(fmt.Sprintf("api/file%d.proto", i)),
+			Package: proto.String("bench.api"),
+		}
+		for m := 0; m < 5; m++ {
+			file.MessageType = append(file.MessageType, &descriptor.DescriptorProto{
+				Name: proto.String(fmt.Sprintf("message_%d", m)),
+				Field: []*descriptor.FieldDescriptorProto{
+					{Name: proto.String("id"), Type: descriptor.FieldDescriptorProto_TYPE_STRING.Enum()},
+					{Name: proto.String("count"), Type: descriptor.FieldDescriptorProto_TYPE_INT64.Enum()},
+				},
+			})
+		}
+		files[i] = file
+	}
+	return files
+}
+
+// BenchmarkRender renders N files x M templates, every encoder walking and parsing the template directory,
+// as before template sets, or the encoders sharing a template set loaded once.
+func BenchmarkRender(b *testing.B) {
+	for _, size := range []struct{ files, templates int }{{10, 5}, {50, 10}, {100, 20}} {
+		dir := writeBenchmarkTemplates(b, size.templates)
+		files := benchmarkFiles(size.files)
+
+		b.Run(fmt.Sprintf("per-encoder/%dx%d", size.files, size.templates), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for j, file := range files {
+					NewGenericTemplateBasedEncoder(dir, file, false, ".", j).Files()
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("shared/%dx%d", size.files, size.templates), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				set, err := LoadTemplateSet(dir, Filter{}, nil, false)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for j, file := range files {
+					e := NewGenericTemplateBasedEncoder(dir, file, false, ".", j)
+					e.SetTemplateSet(set)
+					e.Files()
+				}
+			}
+		})
+	}
+}
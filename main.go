@@ -1,30 +1,23 @@
 package main
 
 import (
-	"fmt"
-	"github.com/chrismoran-blockfi/protoc-gen-gotemplate/helpers"
+	"errors"
+	"flag"
 	"google.golang.org/protobuf/proto"
 	plugingo "google.golang.org/protobuf/types/pluginpb"
 	"io/ioutil"
 	"log"
 	"os"
 	"sort"
-	"strconv"
 	"strings"
 )
 
-var (
-	registry *helpers.Registry
-)
-
-const (
-	boolTrue  = "true"
-	boolFalse = "false"
-)
-
 type Generator struct {
 	Request  *plugingo.CodeGeneratorRequest  // The input.
 	Response *plugingo.CodeGeneratorResponse // The output.
+
+	// sources holds the outputs concatenated into every generated file.
+	sources map[*plugingo.CodeGeneratorResponse_File][]source
 }
 
 func NewGenerator() *Generator {
@@ -48,168 +41,106 @@ func Fail(msgs ...string) {
 	os.Exit(1)
 }
 
-func main() {
-	g := NewGenerator()
+// errUsage is returned by the commands called with invalid arguments, once their usage is printed.
+var errUsage = errors.New("invalid usage")
 
-	data, err := ioutil.ReadAll(os.Stdin)
-	if err != nil {
-		Error(err, "reading input")
-	}
+// commands are the subcommands of the plugin, protoc runs it without arguments.
+var commands = map[string]func(args []string) error{
+	"prune":  pruneCommand,
+	"render": renderCommand,
+	"replay": replayCommand,
+	"test":   testCommand,
+}
 
-	if err = proto.Unmarshal(data, g.Request); err != nil {
-		Error(err, "parsing input proto")
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
 
-	if len(g.Request.FileToGenerate) == 0 {
-		Fail("no files to generate")
+// runCommand runs a subcommand, it returns the exit status.
+func runCommand(name string, args []string) int {
+	command, ok := commands[name]
+	if !ok {
+		log.Printf("protoc-gen-gotemplate: unknown command %q, expected one of: %s", name, strings.Join(commandNames(), ", "))
+		return 2
 	}
-
-	// Parse parameters
-	var (
-		templateDir       = "./templates"
-		destinationDir    = "."
-		index             = -1
-		debug             = false
-		all               = false
-		singlePackageMode = false
-		fileMode          = false
-	)
-	if parameter := g.Request.GetParameter(); parameter != "" {
-		for _, param := range strings.Split(parameter, ",") {
-			parts := strings.Split(param, "=")
-			if len(parts) != 2 {
-				log.Printf("Err: invalid parameter: %q", param)
-				continue
-			}
-			switch parts[0] {
-			case "index":
-				index, err = strconv.Atoi(parts[1])
-				if err != nil {
-					log.Printf("Could not convert %s to an integer", parts[1])
-				}
-			case "template_dir":
-				templateDir = parts[1]
-			case "destination_dir":
-				destinationDir = parts[1]
-			case "single-package-mode":
-				switch strings.ToLower(parts[1]) {
-				case boolTrue, "t":
-					singlePackageMode = true
-				case boolFalse, "f":
-				default:
-					log.Printf("Err: invalid value for single-package-mode: %q", parts[1])
-				}
-			case "debug":
-				switch strings.ToLower(parts[1]) {
-				case boolTrue, "t":
-					debug = true
-				case boolFalse, "f":
-				default:
-					log.Printf("Err: invalid value for debug: %q", parts[1])
-				}
-			case "all":
-				switch strings.ToLower(parts[1]) {
-				case boolTrue, "t":
-					all = true
-				case boolFalse, "f":
-				default:
-					log.Printf("Err: invalid value for all: %q", parts[1])
-				}
-			case "file-mode":
-				switch strings.ToLower(parts[1]) {
-				case boolTrue, "t":
-					fileMode = true
-				case boolFalse, "f":
-				default:
-					log.Printf("Err: invalid value for file-mode: %q", parts[1])
-				}
-			default:
-				log.Printf("Err: unknown parameter: %q", param)
-			}
-		}
+	err := command(args)
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
 	}
+	log.Print("protoc-gen-gotemplate: error: ", err)
+	return 1
+}
 
-	tmplMap := make(map[string]*plugingo.CodeGeneratorResponse_File)
-	ipMap := make(map[string]bool)
-	concatOrAppend := func(file *plugingo.CodeGeneratorResponse_File) {
-		key := fmt.Sprintf("%s:%s", file.GetName(), file.GetInsertionPoint())
-		baseFile := fmt.Sprintf("%s:", file.GetName())
-
-		if val, ok := tmplMap[key]; ok {
-			*val.Content += file.GetContent()
-		} else {
-			if key == baseFile {
-				tmplMap[key] = file
-				ipMap[key] = true
-			}
-			if exists, isOk := ipMap[baseFile]; !isOk || !exists {
-				if debug {
-					_, _ = fmt.Fprintf(os.Stderr, "%s does not exist, skipping %s\n", baseFile, key)
-				}
+// generate runs the templates for a request, failures are reported to protoc through the response,
+// which then discards the generated files.
+func generate(request *plugingo.CodeGeneratorRequest) *plugingo.CodeGeneratorResponse {
+	g := NewGenerator()
+	g.Request = request
+
+	opts, err := parseParameters(g.Request.GetParameter())
+	var dumps []*plugingo.CodeGeneratorResponse_File
+	if err == nil && opts.dumpRequest != "" {
+		dumps, err = dumpRequest(g.Request, opts)
+	}
+	if errors.Is(err, errHelp) {
+		g.Response.Error = proto.String(usage())
+	} else if err != nil {
+		g.Response.Error = proto.String(err.Error())
+	} else if err = g.Generate(opts); err != nil {
+		g.Response.File = nil
+		g.Response.Error = proto.String(err.Error())
+		// protoc discards the outputs of a failed generation, the dump goes to the working directory instead
+		if len(dumps) > 0 {
+			if err := writeResponse(&plugingo.CodeGeneratorResponse{File: dumps}, "."); err != nil {
+				*g.Response.Error += "\ndump_request: " + err.Error()
 			} else {
-				tmplMap[key] = file
-				ipMap[baseFile] = true
-				g.Response.File = append(g.Response.File, file)
+				*g.Response.Error += "\nrequest dumped to " + opts.dumpRequest
 			}
 		}
-	}
-
-	if singlePackageMode {
-		registry = helpers.NewRegistry()
-		helpers.SetRegistry(registry)
-		if err = registry.Load(g.Request); err != nil {
-			Error(err, "registry: failed to load the request")
+	} else if opts.plan || opts.diff || opts.check {
+		// nothing is written, the outputs are reported on the standard error, which protoc passes through
+		if err = g.review(os.Stderr, opts); err != nil {
+			g.Response.Error = proto.String(err.Error())
 		}
+		g.Response.File = nil
+	} else {
+		g.Response.File = append(g.Response.File, dumps...)
 	}
 
-	baseIndex := 0
-	// Generate the encoders
-	rfs := helpers.RequestFileSorter{
-		Request: g.Request,
-	}
-	sort.Sort(rfs)
-	for _, file := range rfs.Request.GetProtoFile() {
-		templateIndex := index
-		if index == -1 {
-			templateIndex = baseIndex
-		}
-		baseIndex = baseIndex + 1
-		if all {
-			if singlePackageMode {
-				if _, err = registry.LookupFile(file.GetName()); err != nil {
-					Error(err, "registry: failed to lookup file %q", file.GetName())
-				}
-			}
-			encoder := helpers.NewGenericTemplateBasedEncoder(templateDir, file, debug, destinationDir, templateIndex)
-			for _, tmpl := range encoder.Files() {
-				concatOrAppend(tmpl)
-			}
+	g.Response.SupportedFeatures = proto.Uint64(uint64(plugingo.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL))
+	return g.Response
+}
 
-			continue
-		}
+func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
 
-		if fileMode {
-			if s := file.GetService(); s != nil && len(s) > 0 {
-				encoder := helpers.NewGenericTemplateBasedEncoder(templateDir, file, debug, destinationDir, templateIndex)
-				for _, tmpl := range encoder.Files() {
-					concatOrAppend(tmpl)
-				}
-			}
+	// protoc writes the request to a pipe, a terminal means the plugin was run by hand
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		log.Print("protoc-gen-gotemplate: run by protoc, or with one of the commands: ", strings.Join(commandNames(), ", "))
+		os.Exit(2)
+	}
 
-			continue
-		}
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		Error(err, "reading input")
+	}
 
-		for _, service := range file.GetService() {
-			encoder := helpers.NewGenericServiceTemplateBasedEncoder(templateDir, service, file, debug, destinationDir, templateIndex)
-			for _, tmpl := range encoder.Files() {
-				concatOrAppend(tmpl)
-			}
-		}
+	request := new(plugingo.CodeGeneratorRequest)
+	if err = proto.Unmarshal(data, request); err != nil {
+		Error(err, "parsing input proto")
 	}
-	// Generate the protobufs
-	g.Response.SupportedFeatures = proto.Uint64(uint64(plugingo.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL))
 
-	data, err = proto.Marshal(g.Response)
+	data, err = proto.Marshal(generate(request))
 	if err != nil {
 		Error(err, "failed to marshal output proto")
 	}
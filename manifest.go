@@ -0,0 +1,231 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	plugingo "google.golang.org/protobuf/types/pluginpb"
+)
+
+// manifest lists the files generated by a run, it is written by the manifest parameter.
+// Its name and the names of the files are relative to the output directory of protoc.
+type manifest struct {
+	Name  string         `json:"name"`
+	Files []manifestFile `json:"files"`
+}
+
+// manifestFile is a generated file, or an insertion into a file.
+type manifestFile struct {
+	Name           string           `json:"name"`
+	InsertionPoint string           `json:"insertion_point,omitempty"`
+	SHA256         string           `json:"sha256"`
+	Sources        []manifestSource `json:"sources"`
+}
+
+// manifestSource is a rendering concatenated into a generated file.
+type manifestSource struct {
+	ProtoFile string `json:"proto_file"`
+	Element   string `json:"element"`
+	Template  string `json:"template"`
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// addManifest adds the manifest of the generated files to the response.
+// The hash of a generated file is the one of its content once the insertions of the run are made,
+// as protoc writes it; the hash of an insertion is the one of the inserted content.
+func (g *Generator) addManifest(name string) error {
+	written := make(map[string]string)
+	for _, file := range g.Response.File {
+		if file.GetInsertionPoint() == "" {
+			written[file.GetName()] = file.GetContent()
+		} else if content, ok := written[file.GetName()]; ok {
+			if content, err := insert(content, file.GetInsertionPoint(), file.GetContent()); err == nil {
+				written[file.GetName()] = content
+			}
+		}
+	}
+
+	m := manifest{Name: name, Files: make([]manifestFile, 0, len(g.Response.File))}
+	for _, file := range g.Response.File {
+		content := file.GetContent()
+		if file.GetInsertionPoint() == "" {
+			content = written[file.GetName()]
+		}
+		mf := manifestFile{
+			Name:           file.GetName(),
+			InsertionPoint: file.GetInsertionPoint(),
+			SHA256:         contentHash(content),
+		}
+		for _, src := range g.sources[file] {
+			mf.Sources = append(mf.Sources, manifestSource{
+				ProtoFile: src.ProtoFile,
+				Element:   src.Element,
+				Template:  src.Template,
+			})
+		}
+		m.Files = append(m.Files, mf)
+	}
+	sort.SliceStable(m.Files, func(i, j int) bool {
+		if m.Files[i].Name != m.Files[j].Name {
+			return m.Files[i].Name < m.Files[j].Name
+		}
+		return m.Files[i].InsertionPoint < m.Files[j].InsertionPoint
+	})
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("manifest: %w", err)
+	}
+	g.Response.File = append(g.Response.File, &plugingo.CodeGeneratorResponse_File{
+		Name:    proto.String(name),
+		Content: proto.String(string(data) + "\n"),
+	})
+	return nil
+}
+
+func loadManifest(path string) (*manifest, error) {
+	data, err := ioutil.ReadFile(path) // #nosec
+	if err != nil {
+		return nil, err
+	}
+	m := &manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// pruneCommand deletes the files listed by a previous manifest which are not generated anymore:
+//
+//	protoc-gen-gotemplate prune -previous old-manifest.json gen/.gotemplate-manifest.json
+//
+// The files are looked up in the output directory of the current manifest, the directory its name is
+// relative to, unless -dir is given.
+// Files modified since their generation are kept, unless -force is given.
+func pruneCommand(args []string) error {
+	flags := flag.NewFlagSet("prune", flag.ContinueOnError)
+	previousPath := flags.String("previous", "", "manifest of the previous generation (required)")
+	dir := flags.String("dir", "", "directory of the generated files (default: the output directory of the current manifest)")
+	dryRun := flags.Bool("dry-run", false, "list the files which would be deleted, without deleting them")
+	force := flags.Bool("force", false, "delete the files modified since their generation")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: protoc-gen-gotemplate prune -previous <manifest> [flags] <current manifest>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *previousPath == "" || flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+	currentPath := flags.Arg(0)
+
+	previous, err := loadManifest(*previousPath)
+	if err != nil {
+		return err
+	}
+	current, err := loadManifest(currentPath)
+	if err != nil {
+		return err
+	}
+	if *dir == "" {
+		if *dir, err = outputDir(currentPath, current); err != nil {
+			return err
+		}
+	}
+	generated := make(map[string]bool, len(current.Files))
+	for _, f := range current.Files {
+		generated[f.Name] = true
+	}
+
+	var errs []string
+	for _, f := range previous.Files {
+		// insertions are made into files generated by other plugins
+		if f.InsertionPoint != "" || generated[f.Name] {
+			continue
+		}
+		path := filepath.Join(*dir, filepath.FromSlash(f.Name))
+		if rel, err := filepath.Rel(*dir, path); err != nil || strings.HasPrefix(rel, "..") {
+			errs = append(errs, fmt.Sprintf("%s: outside of %s, kept", f.Name, *dir))
+			continue
+		}
+		data, err := ioutil.ReadFile(path) // #nosec
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if contentHash(string(data)) != f.SHA256 && !*force {
+			errs = append(errs, fmt.Sprintf("%s: modified since its generation, kept", path))
+			continue
+		}
+		if *dryRun {
+			fmt.Println("would delete", path)
+			continue
+		}
+		fmt.Println("deleting", path)
+		if err := os.Remove(path); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		removeEmptyDirs(filepath.Dir(path), *dir)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("prune:\n%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// outputDir returns the output directory of a manifest read from path, by removing its name from the path.
+// The manifests written without their name are in their output directory.
+func outputDir(path string, m *manifest) (string, error) {
+	if m.Name == "" {
+		return filepath.Dir(path), nil
+	}
+	path = filepath.Clean(path)
+	name := filepath.Clean(filepath.FromSlash(m.Name))
+	if path == name {
+		return ".", nil
+	}
+	if !strings.HasSuffix(path, string(filepath.Separator)+name) {
+		return "", fmt.Errorf("%s: not written as %s, give the directory of the generated files with -dir", path, m.Name)
+	}
+	if dir := path[:len(path)-len(name)-1]; dir != "" {
+		return dir, nil
+	}
+	return string(filepath.Separator), nil
+}
+
+// removeEmptyDirs removes dir and its parents up to root, as long as they are empty.
+func removeEmptyDirs(dir string, root string) {
+	for {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			return
+		}
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			return
+		}
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutputDir(t *testing.T) {
+	for _, test := range []struct {
+		path, name, dir string
+		err             bool
+	}{
+		{path: "gen/manifest.json", name: "manifest.json", dir: "gen"},
+		{path: "gen/meta/manifest.json", name: "meta/manifest.json", dir: "gen"},
+		{path: "./gen/meta/../meta/manifest.json", name: "meta/manifest.json", dir: "gen"},
+		{path: "meta/manifest.json", name: "meta/manifest.json", dir: "."},
+		{path: "/meta/manifest.json", name: "meta/manifest.json", dir: "/"},
+		{path: "gen/manifest.json", name: "", dir: "gen"},
+		{path: "/tmp/previous.json", name: "meta/manifest.json", err: true},
+		{path: "gen/xmeta/manifest.json", name: "meta/manifest.json", err: true},
+	} {
+		dir, err := outputDir(filepath.FromSlash(test.path), &manifest{Name: test.name})
+		if (err != nil) != test.err {
+			t.Errorf("outputDir(%q, %q) error = %v, want error %t", test.path, test.name, err, test.err)
+			continue
+		}
+		if dir != filepath.FromSlash(test.dir) {
+			t.Errorf("outputDir(%q, %q) = %q, want %q", test.path, test.name, dir, test.dir)
+		}
+	}
+}
+
+func TestPruneManifestInSubdirectory(t *testing.T) {
+	out := t.TempDir()
+	write := func(name string, content string) {
+		path := filepath.Join(out, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeManifest := func(path string, files ...string) {
+		m := manifest{Name: "meta/manifest.json"}
+		for _, name := range files {
+			m.Files = append(m.Files, manifestFile{Name: name, SHA256: contentHash(name)})
+		}
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.go", "a.go")
+	write("old/b.go", "old/b.go")
+	previous := filepath.Join(t.TempDir(), "previous.json")
+	writeManifest(previous, "a.go", "old/b.go")
+	write("meta/manifest.json", "")
+	writeManifest(filepath.Join(out, "meta", "manifest.json"), "a.go")
+
+	if err := pruneCommand([]string{"-previous", previous, filepath.Join(out, "meta", "manifest.json")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "a.go")); err != nil {
+		t.Errorf("a.go: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "old")); !os.IsNotExist(err) {
+		t.Errorf("old/b.go and its directory should be deleted: %v", err)
+	}
+}
@@ -0,0 +1,535 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chrismoran-blockfi/protoc-gen-gotemplate/helpers"
+)
+
+const (
+	boolTrue  = "true"
+	boolFalse = "false"
+)
+
+// errHelp is returned by parseParameters when the help parameter is given.
+var errHelp = errors.New("help requested")
+
+// options holds the plugin parameters.
+type options struct {
+	templateDirs      []string
+	destinationDir    string
+	index             int
+	debug             bool
+	all               bool
+	singlePackageMode bool
+	fileMode          bool
+	dropEmpty         bool
+	scope             helpers.Scope
+	jobs              int
+	// pkgMap maps proto files to go import paths, from the M parameters.
+	pkgMap map[string]string
+	// files selects the proto files rendered, templates the templates of the template directories.
+	files        helpers.Filter
+	templates    helpers.Filter
+	outputPrefix string
+	// includeImports renders the templates for the imported files too, not only the files to generate.
+	includeImports bool
+	reproducible   bool
+	validate       bool
+	manifest       string
+	// dumpRequest is the output the request is written to, along with its JSON rendering when dumpRequestJSON.
+	dumpRequest     string
+	dumpRequestJSON bool
+	// plan and diff report the outputs instead of writing them, check compares them with the files on disk.
+	plan  bool
+	diff  bool
+	check bool
+	// postProcess enables the default post-processors, postProcessing holds the ones given by extension.
+	postProcess    bool
+	postProcessing helpers.PostProcessing
+	// header is the template of the header of the outputs, read from headerName.
+	// headerInsertions makes the insertions start with its marker.
+	header           string
+	headerName       string
+	headerInsertions bool
+	vars             map[string]interface{}
+	config           string
+}
+
+func newOptions() *options {
+	return &options{
+		destinationDir: ".",
+		index:          -1,
+		pkgMap:         make(map[string]string),
+		vars:           make(map[string]interface{}),
+		postProcessing: make(helpers.PostProcessing),
+	}
+}
+
+// job returns the options of a job of the configuration file, inheriting the options of the run.
+// The repeated parameters given by the job replace the ones of the run.
+func (opts *options) job() *options {
+	job := *opts
+	job.templateDirs = nil
+	job.files = helpers.Filter{}
+	job.templates = helpers.Filter{}
+	job.vars = make(map[string]interface{}, len(opts.vars))
+	for name, value := range opts.vars {
+		job.vars[name] = value
+	}
+	job.postProcessing = make(helpers.PostProcessing, len(opts.postProcessing))
+	for ext, names := range opts.postProcessing {
+		job.postProcessing[ext] = names
+	}
+	return &job
+}
+
+// defaultScope returns the scope of the templates without a front matter scope.
+func (opts *options) defaultScope() helpers.Scope {
+	if opts.scope != "" {
+		return opts.scope
+	}
+	if opts.all || opts.fileMode {
+		return helpers.ScopeFile
+	}
+	return helpers.ScopeService
+}
+
+// postProcessors returns the post-processors applied to the outputs, by extension.
+func (opts *options) postProcessors() helpers.PostProcessing {
+	postProcessing := make(helpers.PostProcessing)
+	if opts.postProcess {
+		for ext, names := range helpers.DefaultPostProcessing {
+			postProcessing[ext] = names
+		}
+	}
+	for ext, names := range opts.postProcessing {
+		postProcessing[ext] = names
+	}
+	return postProcessing
+}
+
+// generates reports whether the templates are rendered for a proto file.
+func (opts *options) generates(file string) bool {
+	return opts.files.Match(file)
+}
+
+// parameter describes a plugin parameter.
+type parameter struct {
+	name     string
+	value    string
+	usage    string
+	repeated bool
+	// keyed parameters carry a part of their value in their key, after their name.
+	keyed bool
+	// global parameters apply to the whole run, they cannot be set by the jobs of the configuration file.
+	global bool
+	set    func(opts *options, value string) error
+}
+
+// parameters lists the supported plugin parameters, in the order they are documented.
+var parameters = []parameter{
+	{name: "template_dir", value: "path", repeated: true, usage: "directory of the templates, can be repeated (default ./templates)",
+		set: func(opts *options, value string) error {
+			opts.templateDirs = append(opts.templateDirs, value)
+			return nil
+		}},
+	{name: "destination_dir", value: "path", usage: "base path of the outputs, exposed as .DestinationDir (default .)",
+		set: func(opts *options, value string) error {
+			opts.destinationDir = value
+			return nil
+		}},
+	{name: "output_prefix", value: "path", usage: "directory prepended to the output filenames",
+		set: func(opts *options, value string) error {
+			opts.outputPrefix = value
+			return nil
+		}},
+	{name: "include_files", value: "glob", repeated: true, usage: "only render the templates for the proto files matching the pattern, can be repeated",
+		set: func(opts *options, value string) error {
+			return addPattern(&opts.files.Include, value)
+		}},
+	{name: "exclude_files", value: "glob", repeated: true, usage: "do not render the templates for the proto files matching the pattern, can be repeated",
+		set: func(opts *options, value string) error {
+			return addPattern(&opts.files.Exclude, value)
+		}},
+	{name: "include_templates", value: "glob", repeated: true, usage: "only render the templates whose path in template_dir matches the pattern, can be repeated",
+		set: func(opts *options, value string) error {
+			return addPattern(&opts.templates.Include, value)
+		}},
+	{name: "exclude_templates", value: "glob", repeated: true, usage: "do not render the templates whose path in template_dir matches the pattern, can be repeated",
+		set: func(opts *options, value string) error {
+			return addPattern(&opts.templates.Exclude, value)
+		}},
+	{name: "include_imports", value: "bool", usage: "render the templates for the imported proto files too, not only the files given to protoc",
+		set: func(opts *options, value string) (err error) {
+			opts.includeImports, err = parseBool(value)
+			return err
+		}},
+	{name: "reproducible", value: "bool", global: true, usage: "make the outputs independent of the time and the machine, BuildDate is read from SOURCE_DATE_EPOCH",
+		set: func(opts *options, value string) (err error) {
+			opts.reproducible, err = parseBool(value)
+			return err
+		}},
+	{name: "postprocess", value: "bool", usage: "post-process the outputs: gofmt for .go, json for .json",
+		set: func(opts *options, value string) (err error) {
+			opts.postProcess, err = parseBool(value)
+			return err
+		}},
+	{name: "postprocess.", value: "ext=processors", repeated: true, keyed: true, usage: "post-processors of the outputs with the extension, * for any, e.g. postprocess.ts=blank_lines+newlines",
+		set: func(opts *options, value string) error {
+			ext, list, _ := strings.Cut(value, "=")
+			names, err := helpers.ParsePostProcessors(list)
+			if err != nil {
+				return err
+			}
+			opts.postProcessing[strings.TrimPrefix(ext, ".")] = names
+			return nil
+		}},
+	{name: "header", value: "template", usage: "template of a license banner, prepended to the outputs along with a \"Code generated\" marker",
+		set: func(opts *options, value string) error {
+			opts.header, opts.headerName = value, "header"
+			return nil
+		}},
+	{name: "header_file", value: "path", usage: "file holding the template of the header, see header",
+		set: func(opts *options, value string) error {
+			data, err := ioutil.ReadFile(value) // #nosec
+			if err != nil {
+				return err
+			}
+			opts.header, opts.headerName = string(data), value
+			return nil
+		}},
+	{name: "header_insertions", value: "bool", usage: "also start the insertions with the \"Code generated\" marker of the header",
+		set: func(opts *options, value string) (err error) {
+			opts.headerInsertions, err = parseBool(value)
+			return err
+		}},
+	{name: "validate", value: "bool", global: true, usage: "fail when an output cannot be parsed according to its extension: " + strings.Join(validatedExtensions(), ", "),
+		set: func(opts *options, value string) (err error) {
+			opts.validate, err = parseBool(value)
+			return err
+		}},
+	{name: "manifest", value: "path", global: true, usage: "output listing every generated file with its sources and hash, see the prune command",
+		set: func(opts *options, value string) error {
+			opts.manifest = value
+			return nil
+		}},
+	{name: "dump_request", value: "path", global: true, usage: "output the binary request is written to, see the replay command",
+		set: func(opts *options, value string) error {
+			opts.dumpRequest = value
+			return nil
+		}},
+	{name: "dump_request_json", value: "bool", global: true, usage: "also write the request as JSON, to the dump_request path followed by .json",
+		set: func(opts *options, value string) (err error) {
+			opts.dumpRequestJSON, err = parseBool(value)
+			return err
+		}},
+	{name: "plan", value: "bool", global: true, usage: "list the outputs with their sources on the standard error, instead of writing them",
+		set: func(opts *options, value string) (err error) {
+			opts.plan, err = parseBool(value)
+			return err
+		}},
+	{name: "diff", value: "bool", global: true, usage: "print a unified diff of the outputs against the files under destination_dir on the standard error, instead of writing them",
+		set: func(opts *options, value string) (err error) {
+			opts.diff, err = parseBool(value)
+			return err
+		}},
+	{name: "check", value: "bool", global: true, usage: "fail when the outputs differ from the files under destination_dir, instead of writing them",
+		set: func(opts *options, value string) (err error) {
+			opts.check, err = parseBool(value)
+			return err
+		}},
+	{name: "var.", value: "name=value", repeated: true, keyed: true, usage: "variable exposed to the templates as .Vars.<name>, can be repeated",
+		set: func(opts *options, value string) error {
+			name, value, _ := strings.Cut(value, "=")
+			if name == "" {
+				return fmt.Errorf("expected var.<name>=<value>")
+			}
+			opts.vars[name] = value
+			return nil
+		}},
+	{name: "vars_file", value: "path", repeated: true, usage: "JSON or YAML file of variables exposed to the templates as .Vars, can be repeated",
+		set: func(opts *options, value string) error {
+			return loadVarsFile(value, opts.vars)
+		}},
+	{name: "scope", value: "scope", usage: "element the templates are rendered for: " + scopeNames() + " (default service)",
+		set: func(opts *options, value string) (err error) {
+			opts.scope, err = helpers.ParseScope(value)
+			return err
+		}},
+	{name: "all", value: "bool", usage: "render the templates once per file, files without service included",
+		set: func(opts *options, value string) (err error) {
+			opts.all, err = parseBool(value)
+			return err
+		}},
+	{name: "file-mode", value: "bool", usage: "render the templates once per file with services",
+		set: func(opts *options, value string) (err error) {
+			opts.fileMode, err = parseBool(value)
+			return err
+		}},
+	{name: "single-package-mode", value: "bool", global: true, usage: "load every file in a registry, to look up messages across the imported files",
+		set: func(opts *options, value string) (err error) {
+			opts.singlePackageMode, err = parseBool(value)
+			return err
+		}},
+	{name: "M", value: "file=import_path", repeated: true, keyed: true, global: true, usage: "go import path of a proto file, in single-package-mode, can be repeated",
+		set: func(opts *options, value string) error {
+			file, importPath, ok := strings.Cut(value, "=")
+			if !ok || file == "" || importPath == "" {
+				return fmt.Errorf("expected M<file>=<import_path>, got %q", "M"+value)
+			}
+			opts.pkgMap[file] = importPath
+			return nil
+		}},
+	{name: "index", value: "int", usage: "value of .Index, by default the index of the rendered file",
+		set: func(opts *options, value string) (err error) {
+			opts.index, err = strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("expected an integer, got %q", value)
+			}
+			return nil
+		}},
+	{name: "drop_empty", value: "bool", usage: "do not write the outputs containing only whitespaces",
+		set: func(opts *options, value string) (err error) {
+			opts.dropEmpty, err = parseBool(value)
+			return err
+		}},
+	{name: "jobs", value: "int", global: true, usage: "maximum number of templates rendered concurrently (default GOMAXPROCS)",
+		set: func(opts *options, value string) error {
+			jobs, err := strconv.Atoi(value)
+			if err != nil || jobs < 1 {
+				return fmt.Errorf("expected a positive integer, got %q", value)
+			}
+			opts.jobs = jobs
+			return nil
+		}},
+	{name: "debug", value: "bool", global: true, usage: "log verbose output to stderr",
+		set: func(opts *options, value string) (err error) {
+			opts.debug, err = parseBool(value)
+			return err
+		}},
+	{name: "config", value: "path", global: true, usage: "YAML file listing the jobs of the run, see the README",
+		set: func(opts *options, value string) error {
+			opts.config = value
+			return nil
+		}},
+	{name: "help", value: "bool", global: true, usage: "print the supported parameters",
+		set: func(opts *options, value string) error {
+			help, err := parseBool(value)
+			if err != nil || !help {
+				return err
+			}
+			return errHelp
+		}},
+}
+
+func lookupParameter(name string) (*parameter, bool) {
+	for i := range parameters {
+		if parameters[i].name == name {
+			return &parameters[i], true
+		}
+	}
+	return nil, false
+}
+
+func scopeNames() string {
+	names := make([]string, len(helpers.Scopes))
+	for i, scope := range helpers.Scopes {
+		names[i] = string(scope)
+	}
+	return strings.Join(names, ", ")
+}
+
+// addPattern validates a glob pattern and adds it to patterns.
+func addPattern(patterns *[]string, pattern string) error {
+	if err := helpers.ValidateGlob(pattern); err != nil {
+		return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+	*patterns = append(*patterns, pattern)
+	return nil
+}
+
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case boolTrue, "t":
+		return true, nil
+	case boolFalse, "f":
+		return false, nil
+	}
+	return false, fmt.Errorf("expected true or false, got %q", value)
+}
+
+// usage describes every supported parameter.
+func usage() string {
+	var b strings.Builder
+	b.WriteString("protoc-gen-gotemplate parameters, as a comma separated list of key=value, values can be quoted:\n")
+	names := make([]string, len(parameters))
+	width := 0
+	for i, p := range parameters {
+		names[i] = p.name + "=<" + p.value + ">"
+		switch p.name {
+		case "M":
+			names[i] = "M<file>=<import_path>"
+		case "var.":
+			names[i] = "var.<name>=<value>"
+		case "postprocess.":
+			names[i] = "postprocess.<ext>=<processors>"
+		}
+		if len(names[i]) > width {
+			width = len(names[i])
+		}
+	}
+	for i, p := range parameters {
+		fmt.Fprintf(&b, "  %-*s %s\n", width, names[i], p.usage)
+	}
+	return b.String()
+}
+
+// parseParameters parses the parameter string given by protoc.
+// Values containing commas or equal signs can be quoted with single or double quotes,
+// and a parameter without value is a boolean set to true, e.g. `debug,template_dir="a,b"`.
+// Every invalid parameter is reported, along with the valid options.
+func parseParameters(s string) (*options, error) {
+	opts := newOptions()
+	items, err := splitParameters(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	var errs []string
+	seen := make(map[string]bool)
+	for _, item := range items {
+		key, value, hasValue := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		if value, err = unquote(value); err != nil {
+			errs = append(errs, fmt.Sprintf("parameter %q: %v", key, err))
+			continue
+		}
+		name := key
+		// M<file>=<import_path> and var.<name>=<value> carry a part of their value in the key
+		if prefix := keyedPrefix(key); prefix != "" {
+			name, value = prefix, strings.TrimPrefix(key, prefix)+"="+value
+		}
+		p, ok := lookupParameter(name)
+		if !ok || (p.keyed && name == key) {
+			errs = append(errs, fmt.Sprintf("unknown parameter %q, valid parameters are: %s", key, parameterNames()))
+			continue
+		}
+		if !hasValue {
+			if p.value != "bool" {
+				errs = append(errs, fmt.Sprintf("parameter %q: missing value", key))
+				continue
+			}
+			value = boolTrue
+		}
+		if seen[name] && !p.repeated {
+			errs = append(errs, fmt.Sprintf("parameter %q: given more than once", key))
+			continue
+		}
+		seen[name] = true
+		if err := p.set(opts, value); err != nil {
+			if errors.Is(err, errHelp) {
+				return nil, err
+			}
+			errs = append(errs, fmt.Sprintf("parameter %q: %v", key, err))
+		}
+	}
+	if opts.dumpRequestJSON && opts.dumpRequest == "" {
+		errs = append(errs, `parameter "dump_request_json": requires dump_request`)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid parameters:\n%s\n\n%s", strings.Join(errs, "\n"), usage())
+	}
+	if len(opts.templateDirs) == 0 {
+		opts.templateDirs = []string{"./templates"}
+	}
+	return opts, nil
+}
+
+// keyedPrefix returns the name of the keyed parameter of a key holding a part of the value, if any.
+func keyedPrefix(key string) string {
+	for _, p := range parameters {
+		if p.keyed && strings.HasPrefix(key, p.name) && len(key) > len(p.name) {
+			return p.name
+		}
+	}
+	return ""
+}
+
+func parameterNames() string {
+	names := make([]string, 0, len(parameters))
+	for _, p := range parameters {
+		names = append(names, p.name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// splitParameters splits the parameter string on the commas which are not quoted.
+func splitParameters(s string) ([]string, error) {
+	var items []string
+	var current strings.Builder
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0 && r == '\\':
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == ',':
+			if item := strings.TrimSpace(current.String()); item != "" {
+				items = append(items, item)
+			}
+			current.Reset()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", current.String())
+	}
+	if item := strings.TrimSpace(current.String()); item != "" {
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// quoteValue quotes a value for splitParameters and unquote, when it contains a separator or a quote.
+func quoteValue(value string) string {
+	if !strings.ContainsAny(value, ",=\"'\\") {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// unquote removes the quotes around a value, along with the backslash escapes within them.
+func unquote(value string) (string, error) {
+	if len(value) == 0 || (value[0] != '"' && value[0] != '\'') {
+		return value, nil
+	}
+	quote := value[0]
+	if len(value) < 2 || value[len(value)-1] != quote {
+		return "", fmt.Errorf("invalid quoted value %s", value)
+	}
+	var b strings.Builder
+	escaped := false
+	for _, r := range value[1 : len(value)-1] {
+		if !escaped && r == '\\' {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chrismoran-blockfi/protoc-gen-gotemplate/helpers"
+)
+
+func TestSplitParameters(t *testing.T) {
+	for _, test := range []struct {
+		in    string
+		items []string
+		err   string
+	}{
+		{in: "", items: nil},
+		{in: "debug,template_dir=a", items: []string{"debug", "template_dir=a"}},
+		{in: " debug , ,template_dir=a ,", items: []string{"debug", "template_dir=a"}},
+		{in: `template_dir="a,b",debug`, items: []string{`template_dir="a,b"`, "debug"}},
+		{in: `var.x='a,"b"',debug`, items: []string{`var.x='a,"b"'`, "debug"}},
+		{in: `var.x="a\",b",debug`, items: []string{`var.x="a\",b"`, "debug"}},
+		{in: `var.x=a\,b`, items: []string{`var.x=a\`, "b"}},
+		{in: `template_dir="a,b`, err: "unterminated quote"},
+	} {
+		items, err := splitParameters(test.in)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("splitParameters(%q) error = %v, want %q", test.in, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitParameters(%q) error = %v", test.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(items, test.items) {
+			t.Errorf("splitParameters(%q) = %q, want %q", test.in, items, test.items)
+		}
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	for _, test := range []struct {
+		in, out string
+		err     bool
+	}{
+		{in: "", out: ""},
+		{in: "a,b", out: "a,b"},
+		{in: `"a,b"`, out: "a,b"},
+		{in: `'a=b'`, out: "a=b"},
+		{in: `"a\"b"`, out: `a"b`},
+		{in: `"a\\b"`, out: `a\b`},
+		{in: `'it\'s'`, out: "it's"},
+		{in: `a"b"`, out: `a"b"`},
+		{in: `"a`, err: true},
+		{in: `"`, err: true},
+		{in: `"a'`, err: true},
+	} {
+		out, err := unquote(test.in)
+		if (err != nil) != test.err {
+			t.Errorf("unquote(%q) error = %v, want error %t", test.in, err, test.err)
+			continue
+		}
+		if out != test.out {
+			t.Errorf("unquote(%q) = %q, want %q", test.in, out, test.out)
+		}
+	}
+}
+
+func TestQuoteValue(t *testing.T) {
+	for _, value := range []string{"a", "a,b", "a=b", `a"b`, `a\b`, "it's", `"`} {
+		items, err := splitParameters("template_dir=" + quoteValue(value) + ",debug")
+		if err != nil || len(items) != 2 {
+			t.Errorf("splitParameters(quoteValue(%q)) = %q, %v", value, items, err)
+			continue
+		}
+		_, quoted, _ := strings.Cut(items[0], "=")
+		if got, err := unquote(quoted); err != nil || got != value {
+			t.Errorf("unquote(quoteValue(%q)) = %q, %v", value, got, err)
+		}
+	}
+}
+
+func TestKeyedPrefix(t *testing.T) {
+	for key, prefix := range map[string]string{
+		"var.name":       "var.",
+		"var.":           "",
+		"postprocess.ts": "postprocess.",
+		"postprocess.":   "",
+		"postprocess":    "",
+		"Mfoo.proto":     "M",
+		"M":              "",
+		"manifest":       "",
+		"debug":          "",
+	} {
+		if got := keyedPrefix(key); got != prefix {
+			t.Errorf("keyedPrefix(%q) = %q, want %q", key, got, prefix)
+		}
+	}
+}
+
+func TestParseParameters(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		in    string
+		check func(t *testing.T, opts *options)
+		errs  []string
+	}{
+		{
+			name: "defaults",
+			in:   "",
+			check: func(t *testing.T, opts *options) {
+				if !reflect.DeepEqual(opts.templateDirs, []string{"./templates"}) || opts.destinationDir != "." || opts.index != -1 {
+					t.Errorf("unexpected defaults %+v", opts)
+				}
+			},
+		},
+		{
+			name: "quoting and escapes",
+			in:   `template_dir="a,b",destination_dir='c=d',var.x="say \"hi\", \\o/"`,
+			check: func(t *testing.T, opts *options) {
+				if !reflect.DeepEqual(opts.templateDirs, []string{"a,b"}) {
+					t.Errorf("templateDirs = %q", opts.templateDirs)
+				}
+				if opts.destinationDir != "c=d" {
+					t.Errorf("destinationDir = %q", opts.destinationDir)
+				}
+				if got := opts.vars["x"]; got != `say "hi", \o/` {
+					t.Errorf("vars[x] = %q", got)
+				}
+			},
+		},
+		{
+			name: "repeated keys",
+			in:   "template_dir=a,template_dir=b,var.x=1,var.x=2,exclude_files=a/*,exclude_files=b/*",
+			check: func(t *testing.T, opts *options) {
+				if !reflect.DeepEqual(opts.templateDirs, []string{"a", "b"}) {
+					t.Errorf("templateDirs = %q", opts.templateDirs)
+				}
+				if got := opts.vars["x"]; got != "2" {
+					t.Errorf("vars[x] = %q, the last value should win", got)
+				}
+				if !reflect.DeepEqual(opts.files.Exclude, []string{"a/*", "b/*"}) {
+					t.Errorf("files.Exclude = %q", opts.files.Exclude)
+				}
+			},
+		},
+		{
+			name: "keyed prefixes",
+			in:   "Mfoo/bar.proto=example.com/foo,var.name=x=y,postprocess.ts=blank_lines+newlines,postprocess..json=json",
+			check: func(t *testing.T, opts *options) {
+				if got := opts.pkgMap["foo/bar.proto"]; got != "example.com/foo" {
+					t.Errorf("pkgMap = %q", opts.pkgMap)
+				}
+				if got := opts.vars["name"]; got != "x=y" {
+					t.Errorf("vars[name] = %q", got)
+				}
+				want := helpers.PostProcessing{"ts": {"blank_lines", "newlines"}, "json": {"json"}}
+				if !reflect.DeepEqual(opts.postProcessing, want) {
+					t.Errorf("postProcessing = %q, want %q", opts.postProcessing, want)
+				}
+			},
+		},
+		{
+			name: "bare booleans",
+			in:   "debug,all=false,drop_empty=T,include_imports",
+			check: func(t *testing.T, opts *options) {
+				if !opts.debug || opts.all || !opts.dropEmpty || !opts.includeImports {
+					t.Errorf("debug=%t all=%t dropEmpty=%t includeImports=%t", opts.debug, opts.all, opts.dropEmpty, opts.includeImports)
+				}
+			},
+		},
+		{
+			name: "aggregated errors",
+			in:   "nope,debug=maybe,template_dir,index=1,index=2,M=x,var.=y,postprocess.go=prettier,scope=module,dump_request_json",
+			errs: []string{
+				`unknown parameter "nope", valid parameters are: `,
+				`parameter "debug": expected true or false, got "maybe"`,
+				`parameter "template_dir": missing value`,
+				`parameter "index": given more than once`,
+				`unknown parameter "M"`,
+				`unknown parameter "var."`,
+				`parameter "postprocess.go": unknown post-processor "prettier"`,
+				`parameter "scope": `,
+				`parameter "dump_request_json": requires dump_request`,
+				"protoc-gen-gotemplate parameters",
+			},
+		},
+		{
+			name: "invalid quoted value",
+			in:   `template_dir="a"b`,
+			errs: []string{`parameter "template_dir": invalid quoted value`},
+		},
+		{
+			name: "invalid M",
+			in:   "Mfoo.proto",
+			errs: []string{`parameter "Mfoo.proto": missing value`},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			opts, err := parseParameters(test.in)
+			if len(test.errs) > 0 {
+				if err == nil {
+					t.Fatalf("parseParameters(%q) succeeded, want errors", test.in)
+				}
+				for _, want := range test.errs {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("parseParameters(%q) error does not contain %q:\n%v", test.in, want, err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseParameters(%q) error = %v", test.in, err)
+			}
+			test.check(t, opts)
+		})
+	}
+}
+
+func TestParseParametersHelp(t *testing.T) {
+	if _, err := parseParameters("debug,help,nope"); !errors.Is(err, errHelp) {
+		t.Errorf("parseParameters() error = %v, want errHelp", err)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	lines := strings.Split(strings.TrimSuffix(usage(), "\n"), "\n")[1:]
+	column := -1
+	for _, line := range lines {
+		// the usage starts after the longest name and a space
+		name := strings.Fields(line)[0]
+		at := strings.Index(line, name) + len(name)
+		at += len(line[at:]) - len(strings.TrimLeft(line[at:], " "))
+		if column < 0 {
+			column = at
+		}
+		if at != column {
+			t.Errorf("usage of %s starts at column %d, want %d:\n%s", name, at, column, line)
+		}
+	}
+	if len(lines) != len(parameters) {
+		t.Errorf("usage() has %d parameters, want %d", len(lines), len(parameters))
+	}
+}
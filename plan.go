@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// review reports the outputs of the generation instead of writing them, with the plan, diff and check parameters.
+func (g *Generator) review(w io.Writer, opts *options) error {
+	if opts.plan {
+		if err := g.printPlan(w); err != nil {
+			return err
+		}
+	}
+	if opts.diff {
+		if err := g.printDiff(w, opts.destinationDir); err != nil {
+			return err
+		}
+	}
+	if opts.check {
+		return g.check(opts.destinationDir, opts.manifest)
+	}
+	return nil
+}
+
+// printPlan lists the outputs of the generation, with a line for every rendering concatenated into them.
+func (g *Generator) printPlan(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tINSERTION POINT\tPROTO FILE\tTEMPLATE\tCONCATENATED")
+	for _, file := range g.Response.File {
+		insertionPoint := file.GetInsertionPoint()
+		if insertionPoint == "" {
+			insertionPoint = "-"
+		}
+		sources := g.sources[file]
+		if len(sources) == 0 {
+			// the outputs of the plugin itself, such as the manifest
+			fmt.Fprintf(tw, "%s\t%s\t-\t-\tfalse\n", file.GetName(), insertionPoint)
+			continue
+		}
+		for _, src := range sources {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\n", file.GetName(), insertionPoint, src.ProtoFile, src.Template, len(sources) > 1)
+		}
+	}
+	return tw.Flush()
+}
+
+// printDiff prints a unified diff of every output against the file under dir, once the insertions are made.
+func (g *Generator) printDiff(w io.Writer, dir string) error {
+	names, contents, err := resolveOutputs(g.Response, dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fromFile := "a/" + name
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name))) // #nosec
+		if os.IsNotExist(err) {
+			fromFile = "/dev/null"
+		} else if err != nil {
+			return err
+		}
+		if string(data) == contents[name] {
+			continue
+		}
+		err = difflib.WriteUnifiedDiff(w, difflib.UnifiedDiff{
+			A:        splitLines(string(data)),
+			B:        splitLines(contents[name]),
+			FromFile: fromFile,
+			ToFile:   "b/" + name,
+			Context:  3,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitLines splits content into lines ending with a newline, as expected by difflib.
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if last := len(lines) - 1; lines[last] == "" {
+		lines = lines[:last]
+	} else {
+		lines[last] += "\n"
+	}
+	return lines
+}
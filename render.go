@@ -0,0 +1,283 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	descriptor "google.golang.org/protobuf/types/descriptorpb"
+	plugingo "google.golang.org/protobuf/types/pluginpb"
+)
+
+// stringsFlag is a flag which can be repeated.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// renderCommand runs the templates without protoc, on descriptor sets or on a saved request:
+//
+//	protoc-gen-gotemplate render --descriptor_set=api.binpb --templates=./templates --out=./gen
+//
+// The outputs are written under the -out directory, insertions being made into the files generated
+// by the same run or already there.
+func renderCommand(args []string) error {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	var descriptorSets, templateDirs, files stringsFlag
+	flags.Var(&descriptorSets, "descriptor_set", "FileDescriptorSet written by protoc -o or buf build, can be repeated")
+	requestPath := flags.String("request", "", "CodeGeneratorRequest, in binary or as JSON, instead of descriptor sets")
+	flags.Var(&templateDirs, "templates", "template directory, replacing the template_dir parameters, can be repeated")
+	flags.Var(&files, "file", "protobuf file to generate, can be repeated (default: the files of the descriptor sets no other file imports, or the files of the request)")
+	param := flags.String("param", "", "parameters of the plugin, as given to protoc, replacing the ones of the request, e.g. scope=file,debug")
+	out := flags.String("out", ".", "directory the outputs are written to")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: protoc-gen-gotemplate render (-descriptor_set <file>... | -request <file>) [flags]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 || (len(descriptorSets) == 0) == (*requestPath == "") {
+		flags.Usage()
+		return errUsage
+	}
+
+	var request *plugingo.CodeGeneratorRequest
+	var err error
+	if *requestPath != "" {
+		request, err = loadRequest(*requestPath)
+	} else {
+		request, err = requestFromDescriptorSets(descriptorSets)
+	}
+	if err != nil {
+		return err
+	}
+	if len(files) > 0 {
+		request.FileToGenerate = files
+	}
+	if err := overrideParameters(request, *param, templateDirs); err != nil {
+		return err
+	}
+
+	return writeResponse(generate(request), *out)
+}
+
+// overrideParameters replaces the parameters of a request by param when it is given, and its template
+// directories by templateDirs when they are given. The parameters named by omit are removed.
+func overrideParameters(request *plugingo.CodeGeneratorRequest, param string, templateDirs []string, omit ...string) error {
+	if param == "" {
+		param = request.GetParameter()
+	}
+	items, err := splitParameters(param)
+	if err != nil {
+		return err
+	}
+	if len(templateDirs) > 0 {
+		omit = append(omit, "template_dir")
+	}
+	params := make([]string, 0, len(items)+len(templateDirs))
+	for _, item := range items {
+		key, _, _ := strings.Cut(item, "=")
+		if !contains(omit, strings.TrimSpace(key)) {
+			params = append(params, item)
+		}
+	}
+	for _, dir := range templateDirs {
+		params = append(params, "template_dir="+quoteValue(dir))
+	}
+	request.Parameter = proto.String(strings.Join(params, ","))
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// loadRequest reads a CodeGeneratorRequest, as JSON when its extension is .json, in binary otherwise.
+func loadRequest(path string) (*plugingo.CodeGeneratorRequest, error) {
+	data, err := ioutil.ReadFile(path) // #nosec
+	if err != nil {
+		return nil, err
+	}
+	request := new(plugingo.CodeGeneratorRequest)
+	if filepath.Ext(path) == ".json" {
+		err = protojson.Unmarshal(data, request)
+	} else {
+		err = proto.Unmarshal(data, request)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return request, nil
+}
+
+// requestFromDescriptorSets merges descriptor sets into a request generating the files no other file imports,
+// as the sets hold the imports of the files given to protoc along with them.
+// The files are sorted after their dependencies, as protoc does.
+func requestFromDescriptorSets(paths []string) (*plugingo.CodeGeneratorRequest, error) {
+	files := map[string]*descriptor.FileDescriptorProto{}
+	origins := map[string]string{}
+	var names []string
+	for _, p := range paths {
+		data, err := ioutil.ReadFile(p) // #nosec
+		if err != nil {
+			return nil, err
+		}
+		set := new(descriptor.FileDescriptorSet)
+		if err := proto.Unmarshal(data, set); err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		for _, file := range set.File {
+			name := file.GetName()
+			if previous, ok := files[name]; ok {
+				if !proto.Equal(previous, file) {
+					return nil, fmt.Errorf("%s: conflicting definitions in %s and %s", name, origins[name], p)
+				}
+				continue
+			}
+			files[name] = file
+			origins[name] = p
+			names = append(names, name)
+		}
+	}
+
+	imported := map[string]bool{}
+	for _, file := range files {
+		for _, dependency := range file.GetDependency() {
+			imported[dependency] = true
+		}
+	}
+	request := &plugingo.CodeGeneratorRequest{}
+	for _, name := range names {
+		if !imported[name] {
+			request.FileToGenerate = append(request.FileToGenerate, name)
+		}
+	}
+	added := map[string]bool{}
+	var add func(name string, importedBy string) error
+	add = func(name string, importedBy string) error {
+		if added[name] {
+			return nil
+		}
+		file, ok := files[name]
+		if !ok {
+			return fmt.Errorf("%s: missing import %s, build the descriptor sets with their imports", importedBy, name)
+		}
+		added[name] = true
+		for _, dependency := range file.GetDependency() {
+			if err := add(dependency, name); err != nil {
+				return err
+			}
+		}
+		request.ProtoFile = append(request.ProtoFile, file)
+		return nil
+	}
+	for _, name := range names {
+		if err := add(name, ""); err != nil {
+			return nil, err
+		}
+	}
+	return request, nil
+}
+
+// writeResponse writes the files of a response under dir, the way protoc does.
+func writeResponse(response *plugingo.CodeGeneratorResponse, dir string) error {
+	if response.Error != nil {
+		return fmt.Errorf("generation failed: %s", response.GetError())
+	}
+	names, contents, err := resolveOutputs(response, dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(target, []byte(contents[name]), 0o644); err != nil { // #nosec
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveOutputs returns the names and the contents of the files a response writes under dir,
+// insertions being made into the files generated by the response or already under dir.
+func resolveOutputs(response *plugingo.CodeGeneratorResponse, dir string) ([]string, map[string]string, error) {
+	contents := map[string]string{}
+	var names []string
+	for _, file := range response.File {
+		name := file.GetName()
+		if name == "" || path.IsAbs(name) || strings.HasPrefix(path.Clean(name), "..") {
+			return nil, nil, fmt.Errorf("invalid output name %q", name)
+		}
+		name = path.Clean(name)
+		if file.GetInsertionPoint() == "" {
+			if _, ok := contents[name]; !ok {
+				names = append(names, name)
+			}
+			contents[name] = file.GetContent()
+			continue
+		}
+
+		content, ok := contents[name]
+		if !ok {
+			data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name))) // #nosec
+			if err != nil {
+				return nil, nil, fmt.Errorf("insertion point %q: %w", file.GetInsertionPoint(), err)
+			}
+			content = string(data)
+			names = append(names, name)
+		}
+		content, err := insert(content, file.GetInsertionPoint(), file.GetContent())
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		contents[name] = content
+	}
+	return names, contents, nil
+}
+
+// insert adds text before the line holding an insertion point, indented as that line.
+func insert(content string, insertionPoint string, text string) (string, error) {
+	marker := "@@protoc_insertion_point(" + insertionPoint + ")"
+	at := strings.Index(content, marker)
+	if at < 0 {
+		return "", fmt.Errorf("insertion point %q not found", insertionPoint)
+	}
+	lineStart := strings.LastIndex(content[:at], "\n") + 1
+	indent := content[lineStart:at]
+	indent = indent[:len(indent)-len(strings.TrimLeft(indent, " \t"))]
+
+	var b strings.Builder
+	b.WriteString(content[:lineStart])
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if line == "" {
+			continue
+		}
+		if line != "\n" {
+			b.WriteString(indent)
+		}
+		b.WriteString(line)
+	}
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString(content[lineStart:])
+	return b.String(), nil
+}
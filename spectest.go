@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"google.golang.org/protobuf/proto"
+	plugingo "google.golang.org/protobuf/types/pluginpb"
+	"gopkg.in/yaml.v3"
+)
+
+// specSuffix is the suffix of the files specifying template tests.
+const specSuffix = ".tmpltest.yaml"
+
+// spec is a template test, its paths are relative to the directory of the spec file.
+// The input is given by exactly one of Protos, DescriptorSets and Request.
+type spec struct {
+	// Protos are compiled with protoc, looking for imports in ProtoPaths, the directory of the spec by default.
+	Protos     []string `yaml:"protos"`
+	ProtoPaths []string `yaml:"proto_paths"`
+	// DescriptorSets are merged into one request, like with the render command.
+	DescriptorSets []string `yaml:"descriptor_sets"`
+	// Request is a request written by dump_request.
+	Request string `yaml:"request"`
+	// Files are the files to generate, by default the protos, or the files of the descriptor sets no other file imports.
+	Files  []string `yaml:"files"`
+	Params string   `yaml:"params"`
+	// Golden is the directory holding the expected outputs, by default the name of the spec followed by .golden,
+	// under testdata so that the go tool ignores the generated Go files.
+	Golden string `yaml:"golden"`
+	// Ignore are regular expressions matching the lines which change on every run, left out of the comparison.
+	Ignore []string `yaml:"ignore"`
+}
+
+// testCommand runs the template tests specified by the *.tmpltest.yaml files found under the given paths:
+//
+//	protoc-gen-gotemplate test [-update] [path...]
+//
+// Every spec renders its input in-process, and its outputs are compared with the files of its golden directory.
+// With -update, the golden directories are rewritten with the outputs instead.
+func testCommand(args []string) error {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	update := flags.Bool("update", false, "rewrite the golden files with the outputs")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: protoc-gen-gotemplate test [-update] [path...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	roots := flags.Args()
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	specs, err := findSpecs(roots)
+	if err != nil {
+		return err
+	}
+	if len(specs) == 0 {
+		return fmt.Errorf("no %s files found", specSuffix)
+	}
+	failed := 0
+	for _, path := range specs {
+		report, err := runSpec(path, *update)
+		if err != nil {
+			report = err.Error() + "\n"
+		}
+		if report == "" {
+			fmt.Printf("ok    %s\n", path)
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL  %s\n%s", path, report)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d template tests failed", failed, len(specs))
+	}
+	return nil
+}
+
+// findSpecs returns the spec files under roots, the hidden directories being skipped.
+func findSpecs(roots []string) ([]string, error) {
+	var specs []string
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() && path != root && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if !entry.IsDir() && strings.HasSuffix(path, specSuffix) {
+				specs = append(specs, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(specs)
+	return specs, nil
+}
+
+// runSpec runs a template test, it returns the differences between the outputs and the golden files.
+// The generation runs from the directory of the spec, so that the paths of the parameters are relative to it.
+func runSpec(path string, update bool) (report string, err error) {
+	data, err := ioutil.ReadFile(path) // #nosec
+	if err != nil {
+		return "", err
+	}
+	s := spec{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&s); err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	if s.Golden == "" {
+		s.Golden = filepath.Join("testdata", strings.TrimSuffix(filepath.Base(path), specSuffix)+".golden")
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if err := os.Chdir(filepath.Dir(path)); err != nil {
+		return "", err
+	}
+	defer func() {
+		if chdirErr := os.Chdir(wd); chdirErr != nil && err == nil {
+			err = chdirErr
+		}
+	}()
+
+	request, err := s.request()
+	if err != nil {
+		return "", err
+	}
+	if len(s.Files) > 0 {
+		request.FileToGenerate = s.Files
+	}
+	if s.Params != "" {
+		request.Parameter = proto.String(s.Params)
+	}
+	response := generate(request)
+	if response.Error != nil {
+		return "", fmt.Errorf("generation failed: %s", response.GetError())
+	}
+	// insertions into files not generated by the test are made into the files next to the spec
+	names, contents, err := resolveOutputs(response, ".")
+	if err != nil {
+		return "", err
+	}
+
+	golden, err := goldenFiles(s.Golden)
+	if err != nil {
+		return "", err
+	}
+	ignore := make([]*regexp.Regexp, len(s.Ignore))
+	for i, expr := range s.Ignore {
+		if ignore[i], err = regexp.Compile(expr); err != nil {
+			return "", fmt.Errorf("ignore: %w", err)
+		}
+	}
+	if update {
+		return "", updateGolden(s.Golden, golden, names, contents)
+	}
+
+	var b strings.Builder
+	for _, name := range names {
+		want, ok := golden[name]
+		if !ok {
+			fmt.Fprintf(&b, "unexpected output %s\n", name)
+			continue
+		}
+		delete(golden, name)
+		want, got := ignoreLines(want, ignore), ignoreLines(contents[name], ignore)
+		if want == got {
+			continue
+		}
+		err := difflib.WriteUnifiedDiff(&b, difflib.UnifiedDiff{
+			A:        splitLines(want),
+			B:        splitLines(got),
+			FromFile: filepath.ToSlash(filepath.Join(s.Golden, name)),
+			ToFile:   name,
+			Context:  3,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	missing := make([]string, 0, len(golden))
+	for name := range golden {
+		missing = append(missing, name)
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		fmt.Fprintf(&b, "missing output %s\n", name)
+	}
+	return b.String(), nil
+}
+
+// ignoreLines removes the lines of content matching one of the expressions.
+func ignoreLines(content string, ignore []*regexp.Regexp) string {
+	if len(ignore) == 0 {
+		return content
+	}
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(content, "\n") {
+		kept := true
+		for _, expr := range ignore {
+			if expr.MatchString(strings.TrimSuffix(line, "\n")) {
+				kept = false
+				break
+			}
+		}
+		if kept {
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
+
+// request builds the request of a spec.
+func (s spec) request() (*plugingo.CodeGeneratorRequest, error) {
+	inputs := 0
+	for _, given := range []bool{len(s.Protos) > 0, len(s.DescriptorSets) > 0, s.Request != ""} {
+		if given {
+			inputs++
+		}
+	}
+	if inputs != 1 {
+		return nil, fmt.Errorf("expected exactly one of protos, descriptor_sets and request")
+	}
+
+	switch {
+	case s.Request != "":
+		return loadRequest(s.Request)
+	case len(s.DescriptorSets) > 0:
+		return requestFromDescriptorSets(s.DescriptorSets)
+	}
+	return s.compileProtos()
+}
+
+// compileProtos compiles the protos of a spec with protoc into a request generating them.
+func (s spec) compileProtos() (*plugingo.CodeGeneratorRequest, error) {
+	protoPaths := s.ProtoPaths
+	if len(protoPaths) == 0 {
+		protoPaths = []string{"."}
+	}
+	var protos []string
+	for _, pattern := range s.Protos {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("protos: no files match %s", pattern)
+		}
+		protos = append(protos, matches...)
+	}
+
+	dir, err := ioutil.TempDir("", "protoc-gen-gotemplate")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	descriptorSet := filepath.Join(dir, "descriptor_set.binpb")
+
+	// the source info holds the comments, which protoc sends to the plugins
+	args := []string{"--include_imports", "--include_source_info", "--descriptor_set_out=" + descriptorSet}
+	for _, protoPath := range protoPaths {
+		args = append(args, "-I"+protoPath)
+	}
+	args = append(args, protos...)
+	cmd := exec.Command("protoc", args...) // #nosec
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if output := strings.TrimSpace(string(output)); output != "" {
+			return nil, fmt.Errorf("protoc: %w\n%s", err, output)
+		}
+		return nil, fmt.Errorf("protoc: %w", err)
+	}
+
+	request, err := requestFromDescriptorSets([]string{descriptorSet})
+	if err != nil {
+		return nil, err
+	}
+	// the files to generate are the protos, named relatively to their proto path
+	request.FileToGenerate = nil
+	for _, file := range protos {
+		request.FileToGenerate = append(request.FileToGenerate, protoName(file, protoPaths))
+	}
+	return request, nil
+}
+
+// protoName returns the name protoc gives to a proto file, relative to the first proto path holding it.
+func protoName(file string, protoPaths []string) string {
+	for _, protoPath := range protoPaths {
+		if rel, err := filepath.Rel(protoPath, file); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(file)
+}
+
+// goldenFiles returns the contents of the files under a golden directory, by slash separated name.
+func goldenFiles(dir string) (map[string]string, error) {
+	files := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && path == dir {
+			return filepath.SkipDir
+		}
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := ioutil.ReadFile(path) // #nosec
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	return files, err
+}
+
+// updateGolden rewrites a golden directory with the outputs, removing the golden files not generated anymore.
+func updateGolden(dir string, golden map[string]string, names []string, contents map[string]string) error {
+	for _, name := range names {
+		delete(golden, name)
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(target, []byte(contents[name]), 0o644); err != nil { // #nosec
+			return err
+		}
+	}
+	for name := range golden {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removeEmptyDirs(filepath.Dir(path), dir)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/chrismoran-blockfi/protoc-gen-gotemplate/helpers"
+	"gopkg.in/yaml.v3"
+)
+
+// validator parses the content of an output, it returns the line of the error, 0 when unknown.
+type validator func(name string, content []byte) (int, error)
+
+// validators are the validators of the outputs, by extension.
+var validators = map[string]validator{
+	"go":   validateGo,
+	"json": validateJSON,
+	"yaml": validateYAML,
+	"yml":  validateYAML,
+	"xml":  validateXML,
+	"toml": validateTOML,
+}
+
+func validatedExtensions() []string {
+	exts := make([]string, 0, len(validators))
+	for ext := range validators {
+		exts = append(exts, "."+ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// validate parses the generated files according to their extension, every invalid file is reported.
+// Insertions are not validated, they are fragments of files generated by another plugin.
+func (g *Generator) validate() error {
+	var errs helpers.Errors
+	for _, file := range g.Response.File {
+		if file.GetInsertionPoint() != "" {
+			continue
+		}
+		ext := strings.TrimPrefix(path.Ext(file.GetName()), ".")
+		validate, ok := validators[ext]
+		if !ok {
+			continue
+		}
+		line, err := validate(file.GetName(), []byte(file.GetContent()))
+		if err != nil {
+			errs = append(errs, newValidationError(file.GetName(), line, ext, g.sources[file], err))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// newValidationError locates an error in a generated file, along with the template which rendered the line.
+func newValidationError(name string, line int, ext string, sources []source, err error) error {
+	location := name
+	if line > 0 {
+		location += ":" + strconv.Itoa(line)
+	}
+	// the sources are sorted by line, the last one starting before the error rendered it
+	var src *source
+	for i := range sources {
+		if i == 0 || sources[i].line <= line {
+			src = &sources[i]
+		}
+	}
+	if src == nil {
+		return fmt.Errorf("%s: invalid %s: %w", location, ext, err)
+	}
+	return fmt.Errorf("%s: invalid %s rendered by %s for %s: %w", location, ext, src.Template, src.ProtoFile, err)
+}
+
+func validateGo(name string, content []byte) (int, error) {
+	_, err := parser.ParseFile(token.NewFileSet(), name, content, parser.AllErrors)
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		return list[0].Pos.Line, errors.New(list[0].Msg)
+	}
+	return 0, err
+}
+
+func validateJSON(_ string, content []byte) (int, error) {
+	var v interface{}
+	err := json.Unmarshal(content, &v)
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return lineAt(content, syntaxErr.Offset), err
+	}
+	return 0, err
+}
+
+// yamlLineRe matches the line of the errors of the YAML decoder.
+var yamlLineRe = regexp.MustCompile(`line (\d+): `)
+
+func validateYAML(_ string, content []byte) (int, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var v interface{}
+		err := decoder.Decode(&v)
+		if err == io.EOF {
+			return 0, nil
+		}
+		if err != nil {
+			msg := strings.TrimPrefix(err.Error(), "yaml: ")
+			if m := yamlLineRe.FindStringSubmatchIndex(msg); m != nil {
+				line, _ := strconv.Atoi(msg[m[2]:m[3]])
+				return line, errors.New(msg[:m[0]] + msg[m[1]:])
+			}
+			return 0, errors.New(msg)
+		}
+	}
+}
+
+func validateXML(_ string, content []byte) (int, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return 0, nil
+		}
+		var syntaxErr *xml.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return syntaxErr.Line, errors.New(syntaxErr.Msg)
+		}
+		if err != nil {
+			return lineAt(content, decoder.InputOffset()), err
+		}
+	}
+}
+
+// tomlLineRe matches the location prefix of the errors of the TOML decoder.
+var tomlLineRe = regexp.MustCompile(`^toml: line \d+(?: \(last key "[^"]*"\))?: `)
+
+func validateTOML(_ string, content []byte) (int, error) {
+	var v interface{}
+	_, err := toml.Decode(string(content), &v)
+	var parseErr toml.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.Position.Line, errors.New(tomlLineRe.ReplaceAllString(parseErr.Error(), ""))
+	}
+	return 0, err
+}
+
+// lineAt returns the line of an offset in content.
+func lineAt(content []byte, offset int64) int {
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+	return bytes.Count(content[:offset], []byte("\n")) + 1
+}